@@ -0,0 +1,439 @@
+// commands.go
+//
+// The named editing commands that keys are bound to (see keymap.go).
+// Names follow GNU readline where it has an equivalent, so bindings read
+// the same as in an inputrc file.
+
+package main
+
+import (
+	"io"
+	"unicode/utf8"
+)
+
+// command is an editing command.  The key that invoked it is in ed.key.
+type command func(ed *lineEditor)
+
+var commands = map[string]command{
+	"self-insert":          (*lineEditor).selfInsert,
+	"quoted-insert":        (*lineEditor).quotedInsert,
+	"backward-delete-char": (*lineEditor).backwardDeleteChar,
+	"delete-char":          (*lineEditor).deleteChar,
+	"overwrite-mode":       (*lineEditor).overwriteMode,
+
+	"bracketed-paste-begin": (*lineEditor).bracketedPasteBegin,
+
+	"backward-char":     (*lineEditor).backwardChar,
+	"forward-char":      (*lineEditor).forwardChar,
+	"backward-word":     (*lineEditor).backwardWord,
+	"forward-word":      (*lineEditor).forwardWord,
+	"beginning-of-line": (*lineEditor).beginningOfLine,
+	"end-of-line":       (*lineEditor).endOfLine,
+
+	"previous-history":       (*lineEditor).previousHistory,
+	"next-history":           (*lineEditor).nextHistory,
+	"reverse-search-history": (*lineEditor).reverseSearchHistory,
+	"complete":               (*lineEditor).complete,
+
+	"kill-line":          (*lineEditor).killLine,
+	"unix-line-discard":  (*lineEditor).unixLineDiscard,
+	"unix-word-rubout":   (*lineEditor).unixWordRubout,
+	"backward-kill-word": (*lineEditor).backwardKillWord,
+	"kill-word":          (*lineEditor).killWord,
+	"yank":               (*lineEditor).yank,
+	"yank-pop":           (*lineEditor).yankPop,
+
+	"upcase-word":     (*lineEditor).upcaseWord,
+	"downcase-word":   (*lineEditor).downcaseWord,
+	"capitalize-word": (*lineEditor).capitalizeWord,
+	"transpose-chars": (*lineEditor).transposeChars,
+
+	"undo":                (*lineEditor).undoEdit,
+	"redo":                (*lineEditor).redoEdit,
+	"revert-line":         (*lineEditor).revertLine,
+	"redraw-current-line": (*lineEditor).refreshHelper,
+	"accept-line":         (*lineEditor).acceptLine,
+	"end-of-file":         (*lineEditor).endOfFile,
+	"vi-movement-mode":    (*lineEditor).viMovementMode,
+	"abort":               (*lineEditor).abort,
+	"interrupt":           (*lineEditor).interrupt,
+}
+
+// ─────────────────────────────────────────────────────────────
+// Inserting and deleting
+// ─────────────────────────────────────────────────────────────
+
+func (ed *lineEditor) selfInsert() {
+	r := rune(ed.key)
+	if ed.filter != nil && !ed.filter(r) {
+		ed.out.Beep()
+		return
+	}
+	ed.startTyping()
+	ed.reserve(utf8.RuneLen(r))
+	var n int
+	if ed.insert {
+		n = insertRune(ed.buffer, ed.cursor, r)
+	} else {
+		n = replaceRune(ed.buffer, ed.cursor, r)
+	}
+	ed.check(n > 0) // buffer full
+	ed.cursor += n
+}
+
+// quotedInsert inserts the next key literally, even a control character.
+// Feed hands that key to insertLiteral.
+func (ed *lineEditor) quotedInsert() {
+	ed.startTyping()
+	ed.quoting = true
+}
+
+func (ed *lineEditor) insertLiteral(key int) {
+	if ed.vi != nil && ed.vi.inserting {
+		ed.vi.cmd = append(ed.vi.cmd, key) // for "." to insert again
+	}
+	n := 0
+	if key > 0 && utf8.ValidRune(rune(key)) {
+		ed.reserve(utf8.RuneLen(rune(key)))
+		n = insertRune(ed.buffer, ed.cursor, rune(key))
+	}
+	ed.check(n > 0)
+	ed.cursor += n
+}
+
+// pastedText is a paste coming in, up to the terminal's end marker.
+type pastedText struct {
+	text []rune
+	prev int // the key before, to fold CR LF into one space
+}
+
+// bracketedPasteBegin inserts the text pasted between the terminal's
+// paste markers as it is, so a pasted newline or control character does
+// not run a command.  Line breaks and tabs become spaces and other
+// control characters are dropped.  Characters the filter rejects are
+// left out and the paste stops where the buffer is full, with a beep
+// either way.  The whole paste is one undo step.
+//
+// Feed hands the keys up to the end marker to pasteKey.
+func (ed *lineEditor) bracketedPasteBegin() {
+	ed.paste = &pastedText{}
+}
+
+func (ed *lineEditor) pasteKey(key int) {
+	p := ed.paste
+	switch {
+	case key == keyPasteEnd:
+		ed.paste = nil
+		ed.endPaste(p.text)
+		return
+	case key == '\n' && p.prev == '\r':
+	case key == '\r', key == '\n', key == keyTab:
+		p.text = append(p.text, ' ')
+	case isSelfInsert(key):
+		p.text = append(p.text, rune(key))
+	}
+	p.prev = key
+}
+
+// endPaste inserts the pasted text, clearing the default in the same
+// undo step.
+func (ed *lineEditor) endPaste(text []rune) {
+	ed.startTyping()
+	ok := true
+	for _, r := range text {
+		if ed.filter != nil && !ed.filter(r) {
+			ok = false
+			continue
+		}
+		ed.reserve(utf8.RuneLen(r))
+		var n int
+		if ed.insert {
+			n = insertRune(ed.buffer, ed.cursor, r)
+		} else {
+			n = replaceRune(ed.buffer, ed.cursor, r)
+		}
+		if n == 0 {
+			ok = false // buffer full
+			break
+		}
+		ed.cursor += n
+	}
+	ed.check(ok)
+
+	if ed.vi != nil && ed.vi.inserting {
+		// Record the text rather than the paste marker, so "." can
+		// insert it again.
+		cmd := ed.vi.cmd[:len(ed.vi.cmd)-1]
+		for _, r := range text {
+			cmd = append(cmd, int(r))
+		}
+		ed.vi.cmd = cmd
+	}
+}
+
+func (ed *lineEditor) backwardDeleteChar() {
+	ed.startTyping()
+	if ed.cursor > 0 {
+		end := ed.cursor
+		ed.cursor = prevCluster(ed.buffer, ed.cursor)
+		deleteRange(ed.buffer, ed.cursor, end)
+	}
+}
+
+func (ed *lineEditor) deleteChar() {
+	if ed.cursor < clen(ed.buffer) {
+		deleteRange(ed.buffer, ed.cursor, nextCluster(ed.buffer, ed.cursor))
+	} else {
+		ed.out.Beep()
+	}
+}
+
+func (ed *lineEditor) overwriteMode() {
+	ed.insert = !ed.insert
+	ed.refreshHelper()
+}
+
+// ─────────────────────────────────────────────────────────────
+// Moving
+// ─────────────────────────────────────────────────────────────
+
+func (ed *lineEditor) backwardChar() {
+	ed.wasKey = true
+	if ed.cursor > 0 {
+		ed.cursor = prevCluster(ed.buffer, ed.cursor)
+	}
+}
+
+func (ed *lineEditor) forwardChar() {
+	ed.wasKey = true
+	if ed.cursor < clen(ed.buffer) {
+		ed.cursor = nextCluster(ed.buffer, ed.cursor)
+	}
+}
+
+func (ed *lineEditor) backwardWord() {
+	ed.wasKey = true
+	ed.cursor = backwardWord(ed.buffer, ed.cursor, ed.wordChars())
+}
+
+func (ed *lineEditor) forwardWord() {
+	ed.wasKey = true
+	ed.cursor = forwardWord(ed.buffer, ed.cursor, ed.wordChars())
+}
+
+func (ed *lineEditor) beginningOfLine() {
+	ed.wasKey = true
+	ed.cursor = 0
+}
+
+func (ed *lineEditor) endOfLine() {
+	ed.wasKey = true
+	ed.cursor = clen(ed.buffer)
+}
+
+// ─────────────────────────────────────────────────────────────
+// History and completion
+// ─────────────────────────────────────────────────────────────
+
+func (ed *lineEditor) previousHistory() {
+	if ed.moveRow(-1) {
+		return
+	}
+	line, ok := ed.hist.prev(cstring(ed.buffer))
+	if ok {
+		ed.setLine(line)
+	}
+	ed.check(ok)
+}
+
+func (ed *lineEditor) nextHistory() {
+	if ed.moveRow(1) {
+		return
+	}
+	line, ok := ed.hist.next(cstring(ed.buffer))
+	if ok {
+		ed.setLine(line)
+	}
+	ed.check(ok)
+}
+
+// moveRow moves the cursor by delta rows of a line wrapped onto several,
+// keeping its column where it can.  It reports false, leaving Up and
+// Down to the history, when not in wrap mode or already on the first or
+// last row.
+func (ed *lineEditor) moveRow(delta int) bool {
+	if !ed.WrapLines {
+		return false
+	}
+	line := ed.buffer[:clen(ed.buffer)]
+	lead := displayWidth([]byte(ed.prompt + ": "))
+	row, col := wrapPosition(line, ed.cursor, lead, ed.width)
+	last, _ := wrapPosition(line, len(line), lead, ed.width)
+	first, _ := wrapPosition(line, 0, lead, ed.width)
+	if row+delta < first || row+delta > last {
+		return false
+	}
+	ed.cursor = wrapOffset(line, row+delta, col, lead, ed.width)
+	return true
+}
+
+func (ed *lineEditor) reverseSearchHistory() {
+	ed.search = newReverseSearch(ed.History)
+	if ed.search == nil {
+		ed.out.Beep()
+		return
+	}
+	ed.clearInput() // the search prompt takes its place
+}
+
+func (ed *lineEditor) complete() {
+	ed.wasKey = true
+	ed.toLastRow() // any list goes below the input
+	start, end, prefix, listed := complete(ed.out, ed.Completer, cstring(ed.buffer), ed.cursor, ed.lastCmd == "complete")
+	if prefix != "" {
+		ed.reserve(len(prefix) - (end - start))
+		if replaceRange(ed.buffer, start, end, prefix) {
+			ed.cursor = start + len(prefix)
+		} else {
+			ed.out.Beep() // does not fit
+		}
+	}
+	if listed {
+		ed.printHelper()
+	}
+}
+
+// ─────────────────────────────────────────────────────────────
+// Killing and yanking
+// ─────────────────────────────────────────────────────────────
+
+// kill moves buffer[start:end] to the kill ring, joining it to the
+// previous kill if that was the last command.
+func (ed *lineEditor) kill(start, end int) {
+	ed.wasKey = true
+	ed.cursor = ed.kills.killRange(ed.buffer, start, end, ed.cursor, isKillCommand(ed.lastCmd))
+}
+
+func (ed *lineEditor) killLine() {
+	ed.kill(ed.cursor, clen(ed.buffer))
+}
+
+func (ed *lineEditor) unixLineDiscard() {
+	ed.kill(0, ed.cursor)
+}
+
+func (ed *lineEditor) unixWordRubout() {
+	ed.kill(backwardWord(ed.buffer, ed.cursor, isNotBlank), ed.cursor)
+}
+
+func (ed *lineEditor) backwardKillWord() {
+	ed.kill(backwardWord(ed.buffer, ed.cursor, ed.wordChars()), ed.cursor)
+}
+
+func (ed *lineEditor) killWord() {
+	ed.kill(ed.cursor, forwardWord(ed.buffer, ed.cursor, ed.wordChars()))
+}
+
+func (ed *lineEditor) yank() {
+	ed.wasKey = true
+	ed.reserve(ed.kills.longest())
+	var ok bool
+	ed.cursor, ok = ed.kills.yank(ed.buffer, ed.cursor)
+	ed.check(ok)
+}
+
+// yankPop only works straight after a yank.
+func (ed *lineEditor) yankPop() {
+	if ed.lastCmd != "yank" && ed.lastCmd != "yank-pop" {
+		ed.out.Beep()
+		return
+	}
+	ed.reserve(ed.kills.longest())
+	var ok bool
+	ed.cursor, ok = ed.kills.yankPop(ed.buffer)
+	ed.check(ok)
+}
+
+// ─────────────────────────────────────────────────────────────
+// Changing words and characters
+// ─────────────────────────────────────────────────────────────
+
+func (ed *lineEditor) caseWord(conv func(first bool, r rune) rune) {
+	ed.wasKey = true
+	ed.reserve(clen(ed.buffer) - ed.cursor) // a case change can lengthen a character
+	var ok bool
+	ed.cursor, ok = caseWord(ed.buffer, ed.cursor, ed.wordChars(), conv)
+	ed.check(ok)
+}
+
+func (ed *lineEditor) upcaseWord()     { ed.caseWord(upcase) }
+func (ed *lineEditor) downcaseWord()   { ed.caseWord(downcase) }
+func (ed *lineEditor) capitalizeWord() { ed.caseWord(capitalize) }
+
+func (ed *lineEditor) transposeChars() {
+	ed.wasKey = true
+	var ok bool
+	ed.cursor, ok = transposeChars(ed.buffer, ed.cursor)
+	ed.check(ok)
+}
+
+// ─────────────────────────────────────────────────────────────
+// Undo, accept and abort
+// ─────────────────────────────────────────────────────────────
+
+func (ed *lineEditor) undoEdit() {
+	ed.wasKey = true
+	var ok bool
+	ed.cursor, ok = ed.undo.undo(ed.buffer)
+	ed.check(ok)
+}
+
+func (ed *lineEditor) redoEdit() {
+	ed.wasKey = true
+	var ok bool
+	ed.cursor, ok = ed.undo.redo(ed.buffer)
+	ed.check(ok)
+}
+
+// revertLine restores the default the line started with.
+func (ed *lineEditor) revertLine() {
+	copy(ed.buffer, ed.saved)
+	ed.cursor = clen(ed.buffer)
+	ed.wasKey = false
+}
+
+func (ed *lineEditor) acceptLine() {
+	line := cstring(ed.buffer)
+	ed.toLastRow() // validate's complaint goes below the input
+	if ed.validate != nil && !ed.validate(line) {
+		ed.out.Beep()
+
+		// Clear the buffer and reset cursor
+		ed.buffer[0] = 0
+		ed.cursor = 0
+		ed.wasKey = false
+
+		ed.printHelper() // afresh, below the complaint
+		return
+	}
+	ed.History.add(line)
+	ed.done = true
+}
+
+func (ed *lineEditor) abort() {
+	ed.done, ed.err = true, ErrCancelled
+}
+
+func (ed *lineEditor) interrupt() {
+	ed.done, ed.err = true, ErrInterrupted
+}
+
+// endOfFile ends the input on an empty line, as Ctrl-D does for the
+// shell, and otherwise deletes the character under the cursor.
+func (ed *lineEditor) endOfFile() {
+	if clen(ed.buffer) == 0 {
+		ed.done, ed.err = true, io.EOF
+		return
+	}
+	ed.deleteChar()
+}
@@ -0,0 +1,124 @@
+// complete.go
+//
+// Tab completion for the editing versions (V4 onward).
+//
+// The editor knows nothing about what is being completed: it asks a
+// Completer for candidates, inserts as much as all of them agree on, and
+// lists them below the input line if Tab is pressed twice in a row.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Completer supplies completions for the line being edited.
+type Completer interface {
+	// Complete is given the line and the cursor position (a byte
+	// offset) and returns the candidates together with the span
+	// line[start:end] that a chosen candidate replaces.
+	Complete(line string, cursor int) (candidates []string, start, end int)
+}
+
+// A Completer may also implement displayer to choose how its candidates
+// are shown when listed, for instance file names without their directory.
+type displayer interface {
+	Display(candidate string) string
+}
+
+// allowedCompleter completes the whole line against a fixed list of
+// responses, such as V6's allowed ones.
+type allowedCompleter []string
+
+func (a allowedCompleter) Complete(line string, cursor int) ([]string, int, int) {
+	var out []string
+	for _, s := range a {
+		if strings.HasPrefix(s, line[:cursor]) {
+			out = append(out, s)
+		}
+	}
+	return out, 0, len(line)
+}
+
+// complete handles one press of Tab on line.  If the span line[start:end]
+// can be extended to the longest prefix common to every candidate, it
+// returns the prefix for the caller to put in its place.  If that adds
+// nothing and again is set (Tab was also the previous key) the
+// candidates are listed below the input line and listed is true, so the
+// caller knows to redraw the helper bar under them.
+func complete(t Terminal, c Completer, line string, cursor int, again bool) (start, end int, prefix string, listed bool) {
+	if c == nil {
+		t.Beep()
+		return 0, 0, "", false
+	}
+	cands, start, end := c.Complete(line, cursor)
+	if len(cands) == 0 {
+		t.Beep()
+		return 0, 0, "", false
+	}
+
+	prefix = commonPrefix(cands)
+	if len(prefix) > cursor-start && prefix != line[start:end] {
+		return start, end, prefix, false
+	}
+
+	if !again || len(cands) == 1 {
+		t.Beep()
+		return 0, 0, "", false
+	}
+	if d, ok := c.(displayer); ok {
+		shown := make([]string, len(cands))
+		for i, cand := range cands {
+			shown[i] = d.Display(cand)
+		}
+		cands = shown
+	}
+	listCandidates(t, cands)
+	return 0, 0, "", true
+}
+
+// commonPrefix returns the longest prefix shared by all of ss, cut back
+// to a character boundary.
+func commonPrefix(ss []string) string {
+	p := ss[0]
+	for _, s := range ss[1:] {
+		n := 0
+		for n < len(p) && n < len(s) && p[n] == s[n] {
+			n++
+		}
+		p = p[:n]
+	}
+	return strings.ToValidUTF8(p, "")
+}
+
+// listCandidates prints cands in columns, sorted down then across like
+// ls, starting on the line below the cursor.
+func listCandidates(t Terminal, cands []string) {
+	width := termWidth(t)
+
+	colWidth := 0
+	for _, c := range cands {
+		colWidth = max(colWidth, displayWidth([]byte(c)))
+	}
+	colWidth += 2
+	cols := max(1, width/colWidth)
+	rows := (len(cands) + cols - 1) / cols
+
+	fmt.Fprint(t, "\r\n")
+	for r := 0; r < rows; r++ {
+		var sb strings.Builder
+		for c := 0; c < cols; c++ {
+			i := c*rows + r
+			if i >= len(cands) {
+				break
+			}
+			sb.WriteString(cands[i])
+			if (c+1)*rows+r < len(cands) {
+				sb.WriteString(strings.Repeat(" ", colWidth-displayWidth([]byte(cands[i]))))
+			}
+		}
+		fmt.Fprint(t, "\r\033[2K") // clear line
+		fmt.Fprint(t, sb.String(), "\r\n")
+	}
+}
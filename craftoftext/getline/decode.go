@@ -0,0 +1,178 @@
+// decode.go
+//
+// Escape-sequence decoding: turning what the terminal sends for arrow,
+// function and editing keys into the named keys of terminal.go.
+//
+// Terminals disagree on the details, so several forms are accepted:
+//
+//	ESC [ A ... ESC [ D, ESC [ H, ESC [ F     arrows, Home, End (CSI)
+//	ESC O A ... ESC O D, ESC O H, ESC O F     the same in keypad mode (SS3)
+//	ESC [ 1 ; 5 C                             with modifiers: 1 + (1 Shift, 2 Alt, 4 Ctrl, 8 Meta)
+//	ESC [ n ~                                 Home Ins Del End PgUp PgDn, F5–F12 (n ; mod ~ with modifiers)
+//	ESC O P ... ESC O S, ESC [ 1 ; 2 P        F1–F4
+//	ESC [ [ A ... ESC [ [ E                   F1–F5 on the Linux console
+//	ESC [ Z                                   Shift-Tab
+//	ESC [ 200 ~ ... ESC [ 201 ~               around pasted text (bracketed paste)
+//	ESC x, ESC ESC [ A                        Alt-x, Alt with a named key
+//
+// Shift on its own is ignored, so Shift-Right is just Right.  A lone Esc
+// is told apart from the start of a sequence by a short timeout (see
+// readKey in termio.go).
+
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// decodeKey decodes the key that starts with first, calling next for
+// the characters after it.  next returns -1 when there are no more,
+// which for the terminal means nothing arrived within escTimeout.  It
+// returns 0 for a sequence it does not know.  Besides the terminal it
+// decodes the key sequences written in an inputrc file.
+func decodeKey(first int, next func() int) int {
+	if first != 27 { // ESC
+		return first
+	}
+
+	ch := next()
+	switch ch {
+	case -1:
+		return 27 // Esc on its own
+	case 27:
+		// Esc Esc: Alt with whatever the second Esc starts.
+		return keyMeta | decodeKey(27, next)
+	case '[':
+		return decodeCSI(next)
+	case 'O':
+		if key := decodeSS3(next); key != -1 {
+			return key
+		}
+	}
+	return keyMeta | ch // Alt-key
+}
+
+// decodeCSI decodes what follows ESC [: parameter characters (digits
+// and ';') and a final character.
+func decodeCSI(next func() int) int {
+	ch := next()
+	if ch == '[' {
+		// Linux console F1–F5: ESC [ [ A … E.
+		if f := next(); f >= 'A' && f <= 'E' {
+			return keyF1 + f - 'A'
+		}
+		return 0
+	}
+
+	var params strings.Builder
+	for ch >= 0x20 && ch <= 0x3F { // parameter and intermediate bytes
+		params.WriteByte(byte(ch))
+		ch = next()
+	}
+	if ch < 0x40 || ch > 0x7E {
+		return 0 // cut short or not a CSI sequence
+	}
+
+	p := strings.Split(params.String(), ";")
+	num := func(i int) int {
+		if i >= len(p) {
+			return 0
+		}
+		n, _ := strconv.Atoi(p[i])
+		return n
+	}
+
+	var key int
+	switch ch {
+	case '~':
+		key = tildeKey(num(0))
+	case 'Z':
+		key = keyBackTab
+	default:
+		key = finalKey(ch)
+	}
+	return withModifier(key, num(1))
+}
+
+// decodeSS3 decodes what follows ESC O, or returns -1 if nothing
+// follows, in which case it was Alt-O.
+func decodeSS3(next func() int) int {
+	ch := next()
+	if ch == -1 {
+		return -1
+	}
+	mod := 0
+	for ch >= '0' && ch <= '9' { // some terminals put a modifier here: ESC O 5 C
+		mod = mod*10 + ch - '0'
+		ch = next()
+	}
+	return withModifier(finalKey(ch), mod)
+}
+
+// finalKey maps the final character of a CSI or SS3 sequence to its
+// key, or 0.
+func finalKey(ch int) int {
+	switch ch {
+	case 'A':
+		return keyUp
+	case 'B':
+		return keyDown
+	case 'C':
+		return keyRight
+	case 'D':
+		return keyLeft
+	case 'H':
+		return keyHome
+	case 'F':
+		return keyEnd
+	case 'P', 'Q', 'R', 'S':
+		return keyF1 + ch - 'P'
+	}
+	return 0
+}
+
+// tildeKey maps the number in ESC [ n ~ to its key, or 0.
+func tildeKey(n int) int {
+	switch n {
+	case 1, 7:
+		return keyHome
+	case 2:
+		return keyIns
+	case 3:
+		return keyDel
+	case 4, 8:
+		return keyEnd
+	case 5:
+		return keyPgUp
+	case 6:
+		return keyPgDn
+	case 11, 12, 13, 14, 15:
+		return keyF1 + n - 11
+	case 17, 18, 19, 20, 21:
+		return keyF1 + 5 + n - 17
+	case 23, 24:
+		return keyF1 + 10 + n - 23
+	case 200:
+		return keyPasteStart
+	case 201:
+		return keyPasteEnd
+	}
+	return 0
+}
+
+// withModifier adds the modifier bits of an xterm modifier parameter
+// (1 + a bit mask) to key.
+func withModifier(key, param int) int {
+	if key == 0 || param < 2 {
+		return key
+	}
+	mod := param - 1
+	if mod&(2|8) != 0 {
+		key |= keyMeta
+	}
+	if mod&4 != 0 {
+		key |= keyCtrl
+	}
+	return key
+}
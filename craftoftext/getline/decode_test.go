@@ -0,0 +1,94 @@
+// decode_test.go
+//
+// Tests of escape-sequence decoding, byte sequences in and keys out.
+
+package main
+
+import "testing"
+
+func TestDecodeKey(t *testing.T) {
+	tests := []struct {
+		seq  string
+		key  int
+		rest string // left unread
+	}{
+		{"a", 'a', ""},
+		{"\r", keyEnter, ""},
+		{"\x1b", 27, ""}, // a lone Esc
+		{"\x1bf", keyMeta | 'f', ""},
+		{"\x1b\x7f", keyMeta | keyBack, ""},
+		{"\x1bO", keyMeta | 'O', ""}, // Alt-O, not a cut-short SS3
+
+		{"\x1b[A", keyUp, ""},
+		{"\x1b[B", keyDown, ""},
+		{"\x1b[C", keyRight, ""},
+		{"\x1b[D", keyLeft, ""},
+		{"\x1b[H", keyHome, ""},
+		{"\x1b[F", keyEnd, ""},
+		{"\x1bOA", keyUp, ""},
+		{"\x1bOH", keyHome, ""},
+		{"\x1bOF", keyEnd, ""},
+		{"\x1b[Z", keyBackTab, ""},
+
+		{"\x1b[1~", keyHome, ""},
+		{"\x1b[7~", keyHome, ""},
+		{"\x1b[2~", keyIns, ""},
+		{"\x1b[3~", keyDel, ""},
+		{"\x1b[4~", keyEnd, ""},
+		{"\x1b[8~", keyEnd, ""},
+		{"\x1b[5~", keyPgUp, ""},
+		{"\x1b[6~", keyPgDn, ""},
+		{"\x1b[200~", keyPasteStart, ""},
+		{"\x1b[201~", keyPasteEnd, ""},
+
+		{"\x1bOP", keyF1, ""},
+		{"\x1bOQ", keyF1 + 1, ""},
+		{"\x1bOR", keyF1 + 2, ""},
+		{"\x1bOS", keyF1 + 3, ""},
+		{"\x1b[11~", keyF1, ""},
+		{"\x1b[15~", keyF1 + 4, ""},
+		{"\x1b[17~", keyF1 + 5, ""},
+		{"\x1b[18~", keyF1 + 6, ""},
+		{"\x1b[19~", keyF1 + 7, ""},
+		{"\x1b[20~", keyF1 + 8, ""},
+		{"\x1b[21~", keyF1 + 9, ""},
+		{"\x1b[23~", keyF1 + 10, ""},
+		{"\x1b[24~", keyF12, ""},
+		{"\x1b[[A", keyF1, ""},
+		{"\x1b[[E", keyF1 + 4, ""},
+
+		{"\x1b[1;2C", keyRight, ""}, // Shift is dropped
+		{"\x1b[1;3C", keyMeta | keyRight, ""},
+		{"\x1b[1;5C", keyCtrl | keyRight, ""},
+		{"\x1b[1;7D", keyMeta | keyCtrl | keyLeft, ""},
+		{"\x1b[1;9A", keyMeta | keyUp, ""},
+		{"\x1b[3;5~", keyCtrl | keyDel, ""},
+		{"\x1b[1;2P", keyF1, ""},
+		{"\x1bO5C", keyCtrl | keyRight, ""},
+		{"\x1b\x1b[A", keyMeta | keyUp, ""},
+
+		{"\x1b[", 0, ""}, // cut short
+		{"\x1b[1;", 0, ""},
+		{"\x1b[[", 0, ""},
+		{"\x1b[99~", 0, ""},
+		{"\x1b[1;5X", 0, ""},
+		{"\x1b[[Z", 0, ""},
+		{"\x1b[Ax", keyUp, "x"}, // only one key is read
+	}
+	for _, tt := range tests {
+		seq := []byte(tt.seq)
+		next := func() int {
+			if len(seq) == 0 {
+				return -1
+			}
+			c := int(seq[0])
+			seq = seq[1:]
+			return c
+		}
+		key := decodeKey(next(), next)
+		if key != tt.key || string(seq) != tt.rest {
+			t.Errorf("decodeKey(%q) = %s, leaving %q; want %s, leaving %q",
+				tt.seq, keyName(key), seq, keyName(tt.key), tt.rest)
+		}
+	}
+}
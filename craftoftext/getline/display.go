@@ -0,0 +1,196 @@
+// display.go
+//
+// Fitting the input line to the width of the terminal.
+//
+// When the line is wider than the space after the prompt, only a window
+// of it is shown — scrolled sideways to keep the cursor in view — with a
+// '<' at the left edge if text is hidden before the window and a '>' at
+// the right edge if text is hidden after it.  The window only moves
+// when the cursor would leave it, and then jumps so the cursor is near
+// the middle, as readline's horizontal-scroll-mode does.
+//
+// The helper bar is cut to the width too, a whole item at a time.
+//
+// In wrap mode the line is instead folded onto as many rows as it needs.
+// The breaks are made here rather than left to the terminal, so a wide
+// character never straddles the margin and the cursor's row and column
+// are always known.
+
+package main
+
+import "strings"
+
+// termWidth returns the width of t in cells, or 80 if it cannot tell.
+func termWidth(t Terminal) int {
+	width, _, err := t.Size()
+	if err != nil || width <= 0 {
+		return 80
+	}
+	return width
+}
+
+// scrollWindow works out what to show of line in width cells with the
+// cursor (a byte offset) in view.  start is where the window began last
+// time; the new start is returned with the text to draw, markers
+// included, and the cursor's column in it.
+//
+// The last column is kept for the '>' marker or for the cursor at the
+// end of the line, so drawing never reaches the terminal's right margin
+// and wraps.
+func scrollWindow(line []byte, cursor, start, width int) (newStart int, text string, col int) {
+	width = max(width, 4)
+	if displayWidth(line) < width {
+		return 0, string(line), displayWidth(line[:cursor])
+	}
+
+	// fits reports whether the cursor is visible in a window at s.
+	fits := func(s int) bool {
+		if s > cursor {
+			return false
+		}
+		used := displayWidth(line[s:cursor])
+		if s > 0 {
+			used++ // '<'
+		}
+		if cursor < len(line) {
+			used += displayWidth(line[cursor:nextCluster(line, cursor)])
+		}
+		return used <= width-1
+	}
+	if !fits(start) {
+		// Recentre: back up from the cursor about half the width.
+		start = cursor
+		for start > 0 && displayWidth(line[start:cursor]) < width/2 {
+			start = prevCluster(line, start)
+		}
+		for !fits(start) {
+			start = nextCluster(line, start)
+		}
+	}
+
+	var buf []byte
+	avail := width - 1
+	if start > 0 {
+		buf = append(buf, '<')
+		avail--
+	}
+	end := start
+	for used := 0; end < len(line); {
+		next := nextCluster(line, end)
+		w := displayWidth(line[end:next])
+		if used+w > avail {
+			break
+		}
+		used += w
+		end = next
+	}
+	buf = append(buf, line[start:end]...)
+	if end < len(line) {
+		buf = append(buf, '>')
+	}
+
+	col = displayWidth(line[start:cursor])
+	if start > 0 {
+		col++
+	}
+	return start, string(buf), col
+}
+
+// wrapPosition returns the row and column, counted from the start of the
+// prompt's row, of the cell for byte offset pos in line when it is
+// folded to width cells after lead cells of prompt.  A character that
+// does not fit at the end of a row starts the next; so does the cursor
+// at the end of a full row.
+func wrapPosition(line []byte, pos, lead, width int) (row, col int) {
+	width = max(width, 2)
+	row, col = lead/width, lead%width
+	for p := 0; p < pos; {
+		next := nextCluster(line, p)
+		w := displayWidth(line[p:next])
+		if col+w > width {
+			row, col = row+1, 0
+		}
+		col += w
+		p = next
+	}
+	w := 1
+	if pos < len(line) {
+		w = max(displayWidth(line[pos:nextCluster(line, pos)]), 1)
+	}
+	if col+w > width {
+		row, col = row+1, 0
+	}
+	return row, col
+}
+
+// wrapText returns line folded to width cells after lead cells of
+// prompt, with a CR LF at each break, and the row of its end.  A line
+// that exactly fills its last row gets a break after it too, so the
+// cursor can sit at the end.
+func wrapText(line []byte, lead, width int) (text string, rows int) {
+	width = max(width, 2)
+	var buf []byte
+	row, col := lead/width, lead%width
+	for p := 0; p < len(line); {
+		next := nextCluster(line, p)
+		w := displayWidth(line[p:next])
+		if col+w > width {
+			buf = append(buf, "\r\n"...)
+			row, col = row+1, 0
+		}
+		buf = append(buf, line[p:next]...)
+		col += w
+		p = next
+	}
+	if col >= width {
+		buf = append(buf, "\r\n"...)
+		row++
+	}
+	return string(buf), row + 1
+}
+
+// wrapOffset returns the byte offset in line shown nearest to col on
+// row, at or before it, for moving the cursor up and down.
+func wrapOffset(line []byte, row, col, lead, width int) int {
+	found := -1
+	for p := 0; ; p = nextCluster(line, p) {
+		r, c := wrapPosition(line, p, lead, width)
+		if r > row {
+			break
+		}
+		if r == row && (c <= col || found < 0) {
+			found = p
+		}
+		if p >= len(line) {
+			break
+		}
+	}
+	return max(found, 0)
+}
+
+// fitHelper cuts a helper bar of items separated by " | " to less than
+// width cells, dropping whole items from the end and marking the cut
+// with '…'.  The last column is left alone, as in scrollWindow.
+func fitHelper(bar string, width int) string {
+	if displayWidth([]byte(bar)) < width {
+		return bar
+	}
+	items := strings.Split(bar, " | ")
+	for n := len(items) - 1; n > 0; n-- {
+		s := strings.Join(items[:n], " | ") + " …"
+		if displayWidth([]byte(s)) < width {
+			return s
+		}
+	}
+	// Not even the first item fits: cut it short.
+	b := []byte(items[0])
+	end := 0
+	for end < len(b) {
+		next := nextCluster(b, end)
+		if displayWidth(b[:next]) >= width-1 {
+			break
+		}
+		end = next
+	}
+	return string(b[:end]) + "…"
+}
@@ -0,0 +1,787 @@
+// editor.go
+//
+// The line editor behind GetLine Versions Four to Six.
+//
+// Each GetLine call creates a lineEditor holding the line being edited
+// and everything that goes with it: cursor, insert/replace mode, history
+// position, undo log.  Every key is looked up in the keymap and the
+// command bound to it (see commands.go) runs against the editor.  The
+// versions differ only in the hooks they set: V5 filters what may be
+// typed, V6 checks the finished line.
+//
+// The editor itself never reads the terminal or writes to it.  It is a
+// state machine: Feed gives it a key, and Render returns what to write
+// to bring the screen up to date.  run drives it from a Terminal for
+// GetLine; a program with an event loop of its own can drive it the
+// same way, feeding keys as they come between its other work.
+//
+// Printf may be called from any goroutine, to print above the prompt
+// while the line is edited; a mutex keeps it and the editing apart.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+type lineEditor struct {
+	lineOptions
+
+	keys   *keymap
+	prompt string
+	buffer []byte // the caller's NUL-terminated buffer, or the editor's own
+	saved  []byte // its original contents, for revert-line
+
+	// growable — the buffer is the editor's own and grows with the
+	//            line, up to MaxLen (see reserve).
+	growable bool
+
+	// cursor — byte offset of the character the cursor sits on; always
+	//          on a UTF-8 character boundary.
+	// wasKey — has the user pressed anything yet?
+	// insert — true = insert mode, false = replace mode.
+	cursor int
+	wasKey bool
+	insert bool
+	scroll int // byte offset of the first character shown (see display.go)
+	width  int // of the terminal, found at the start and on every resize
+
+	// In wrap mode, the rows the line took when last drawn and the one
+	// the cursor was left on, counted from the prompt's row.
+	rows, cursorRow int
+
+	hist   *historyWalk // position in the history for Up/Down recall
+	kills  *killRing
+	undo   *undoLog
+	vi     *viState       // nil unless in vi mode
+	search *reverseSearch // non-nil while searching the history
+
+	// Commands that take the keys after their own: quoting is set by
+	// quoted-insert for the next key, paste by bracketed-paste-begin
+	// until the end of the paste.
+	quoting bool
+	paste   *pastedText
+
+	key     int     // the key being handled
+	lastCmd string  // the command run for the previous key
+	pending *keymap // set after a prefix key such as Ctrl-X
+
+	// filter, if set, decides which typed characters are accepted.
+	filter func(r rune) bool
+
+	// validate, if set, checks the line on Enter.  If it returns false
+	// (having said why) the line is cleared for another try.
+	validate func(line string) bool
+
+	out     *screenBuffer // what the editor draws, for Render
+	started bool          // the helper bar and input have been drawn
+	done    bool          // accept-line, abort or the like has run
+	err     error         // why the line was abandoned, if it was
+
+	// mu guards all of the above, for Printf.  direct is set when run
+	// owns ed.Term, and Printf writes to it rather than leave its
+	// output for Render.
+	mu     sync.Mutex
+	direct bool
+}
+
+// action is what came of a key, as Feed reports it.
+type action int
+
+const (
+	actionNone    action = iota // the key was handled
+	actionBeep                  // the key could not be used: alert the user
+	actionAccept                // the line is finished (see Line)
+	actionAbandon               // the line was given up (see Err)
+)
+
+func newLineEditor(opts lineOptions, prompt string, buffer []byte) *lineEditor {
+	ed := &lineEditor{
+		lineOptions: opts,
+		keys:        opts.bindings(),
+		prompt:      prompt,
+		buffer:      buffer,
+		saved:       make([]byte, len(buffer)),
+		cursor:      clen(buffer), // start at end of any pre-loaded default
+		insert:      true,
+		kills:       opts.kills(),
+		out:         &screenBuffer{width: 80},
+	}
+	if opts.ViMode {
+		ed.keys = viInsertKeymap(ed.keys)
+		ed.vi = &viState{}
+	}
+	ed.Term = orStdTerminal(opts.Term)
+	copy(ed.saved, buffer)
+	ed.hist = ed.History.walk(cstring(buffer))
+	ed.undo = newUndoLog(buffer, ed.cursor)
+	return ed
+}
+
+// ─────────────────────────────────────────────────────────────
+// The state machine
+// ─────────────────────────────────────────────────────────────
+
+// Feed hands the editor a key, as ReadKey returns it, and reports what
+// came of it.  done is true once the line is finished or abandoned;
+// keys fed after that are ignored.  A change of size is reported with
+// Resize, not fed as keyResize.
+func (ed *lineEditor) Feed(key int) (act action, done bool) {
+	ed.mu.Lock()
+	defer ed.mu.Unlock()
+	return ed.feed(key)
+}
+
+func (ed *lineEditor) feed(key int) (act action, done bool) {
+	ed.start()
+	if ed.done {
+		return ed.outcome(), true
+	}
+	ed.out.beeped = false
+	if key == keyResize {
+		return actionNone, false // see Resize
+	}
+	ed.handle(key)
+
+	if ed.done {
+		ed.belowInput()
+		return ed.outcome(), true
+	}
+	// Record what the key changed, if anything, for undo.
+	ed.undo.sync(ed.buffer, ed.cursor, ed.lastCmd == "self-insert")
+	ed.draw()
+	return ed.outcome(), false
+}
+
+// handle gives key to whatever is waiting for it: a search, a command
+// that takes the next key, or else the keymap.
+func (ed *lineEditor) handle(key int) {
+	switch {
+	case ed.search != nil:
+		b, ok := ed.keys.lookup(key)
+		again := ok && b.command == "reverse-search-history"
+		if line, done, ok := ed.search.key(ed.out, key, again); done {
+			ed.search = nil
+			if ok {
+				ed.setLine(line)
+			}
+		}
+	case ed.quoting:
+		ed.quoting = false
+		ed.insertLiteral(key)
+	case ed.paste != nil:
+		ed.pasteKey(key)
+	default:
+		ed.dispatch(key)
+	}
+}
+
+// outcome is the action Feed reports for the key just fed.
+func (ed *lineEditor) outcome() action {
+	switch {
+	case ed.done && ed.err == nil:
+		return actionAccept
+	case ed.done:
+		return actionAbandon
+	case ed.out.beeped:
+		return actionBeep
+	}
+	return actionNone
+}
+
+// Render returns what to write to the terminal to bring it up to date,
+// the first time drawing the helper bar and the prompt.
+func (ed *lineEditor) Render() []byte {
+	ed.mu.Lock()
+	defer ed.mu.Unlock()
+	return ed.render()
+}
+
+func (ed *lineEditor) render() []byte {
+	ed.start()
+	b := bytes.Clone(ed.out.Bytes())
+	ed.out.Reset()
+	return b
+}
+
+// Resize tells the editor the terminal is now width cells wide, and
+// redraws everything to fit.
+func (ed *lineEditor) Resize(width int) {
+	ed.mu.Lock()
+	defer ed.mu.Unlock()
+	ed.resize(width)
+}
+
+func (ed *lineEditor) resize(width int) {
+	ed.width, ed.out.width = width, width
+	if ed.started && !ed.done {
+		ed.withdraw()
+		ed.printHelper()
+		ed.draw()
+	}
+}
+
+// Abandon gives up on the line with err, taking the helper bar and the
+// input off the screen, as GetLineContext does when its context is done.
+func (ed *lineEditor) Abandon(err error) {
+	ed.mu.Lock()
+	defer ed.mu.Unlock()
+	ed.abandon(err)
+}
+
+func (ed *lineEditor) abandon(err error) {
+	if ed.done {
+		return
+	}
+	if ed.started {
+		ed.withdraw()
+	}
+	ed.done, ed.err = true, err
+}
+
+// Line returns the line as it stands.
+func (ed *lineEditor) Line() string {
+	ed.mu.Lock()
+	defer ed.mu.Unlock()
+	return cstring(ed.buffer)
+}
+
+// Err returns why the line was abandoned, or nil (see errors.go).
+func (ed *lineEditor) Err() error {
+	ed.mu.Lock()
+	defer ed.mu.Unlock()
+	return ed.err
+}
+
+// Printf prints a message above the helper bar and the input, and draws
+// them again below it as they were, cursor and all.  A message is given
+// a newline at the end if it has none.  It returns the length of the
+// message, and any error writing it when run is editing on ed.Term;
+// otherwise the message waits for Render.
+func (ed *lineEditor) Printf(format string, a ...any) (int, error) {
+	msg := fmt.Sprintf(format, a...)
+	ed.mu.Lock()
+	defer ed.mu.Unlock()
+
+	shown := ed.started && !ed.done
+	if shown {
+		ed.withdraw()
+	}
+	// In raw mode a line feed does not return the cursor.
+	ed.out.WriteString(strings.ReplaceAll(strings.TrimSuffix(msg, "\n"), "\n", "\r\n") + "\r\n")
+	if shown {
+		ed.printHelper()
+		ed.draw()
+	}
+	if ed.direct {
+		if err := ed.flush(); err != nil {
+			return 0, err
+		}
+	}
+	return len(msg), nil
+}
+
+// start draws the helper bar and the prompt, the first time it is called.
+func (ed *lineEditor) start() {
+	if ed.started {
+		return
+	}
+	ed.started = true
+	ed.width = ed.out.width
+	ed.printHelper()
+	ed.draw()
+}
+
+// stop ends the line with err, leaving it on the screen as accept-line
+// does.
+func (ed *lineEditor) stop(err error) {
+	ed.belowInput()
+	ed.done, ed.err = true, err
+}
+
+// screenBuffer is the Terminal the editor draws on.  It keeps what is
+// written for Render and notes beeps for Feed to report; keys come
+// through Feed, so it has none to read.
+type screenBuffer struct {
+	bytes.Buffer
+	width  int
+	beeped bool
+}
+
+func (s *screenBuffer) ReadKey() (int, error)   { return 0, io.EOF }
+func (s *screenBuffer) Size() (int, int, error) { return s.width, 0, nil } // the height is not needed
+func (s *screenBuffer) MakeRaw() error          { return nil }
+func (s *screenBuffer) Restore() error          { return nil }
+func (s *screenBuffer) Beep()                   { s.beeped = true }
+
+// ─────────────────────────────────────────────────────────────
+// Driving the editor from a Terminal
+// ─────────────────────────────────────────────────────────────
+
+// run edits the line on ed.Term until it is accepted, returning nil, or
+// abandoned, returning why (see errors.go).  If ctx is done first, it
+// takes the helper bar and input off the screen and returns ctx.Err().
+// It holds ed.mu except while it waits for a key.
+func (ed *lineEditor) run(ctx context.Context) error {
+	t := ed.Term
+	if err := t.MakeRaw(); err != nil {
+		return &TermError{"raw mode", err}
+	}
+	defer t.Restore()
+
+	ed.mu.Lock()
+	fmt.Fprint(t, "\033[?2004h") // bracketed paste on
+	ed.direct = true
+	ed.mu.Unlock()
+	defer func() {
+		ed.mu.Lock()
+		fmt.Fprint(t, "\033[?2004l") // and off again
+		ed.mu.Unlock()
+	}()
+
+	// Wake the read when ctx is done.  A terminal that cannot be woken
+	// gives up at the next key instead.
+	if c, ok := t.(readCanceller); ok {
+		stop := context.AfterFunc(ctx, c.cancelRead)
+		defer stop()
+	}
+
+	ed.mu.Lock()
+	defer ed.mu.Unlock()
+	ed.resize(termWidth(t))
+	for {
+		if err := ed.flush(); err != nil {
+			return err
+		}
+		if ed.done {
+			return ed.err
+		}
+		if err := ctx.Err(); err != nil {
+			ed.abandon(err)
+			continue
+		}
+
+		ed.mu.Unlock()
+		key, err := t.ReadKey()
+		ed.mu.Lock()
+
+		switch {
+		case err == errReadCancelled:
+			// ctx is done, or was an earlier call's
+		case err != nil:
+			ed.stop(readError(err))
+		case key == keyResize:
+			ed.resize(termWidth(t))
+		default:
+			if act, _ := ed.feed(key); act == actionBeep {
+				t.Beep()
+			}
+		}
+	}
+}
+
+// flush writes what has been drawn to ed.Term.
+func (ed *lineEditor) flush() error {
+	if _, err := ed.Term.Write(ed.render()); err != nil {
+		return &TermError{"write", err}
+	}
+	return nil
+}
+
+// ReadLine edits the line on ed.Term, as ReadLineContext does, and
+// returns it.  Keeping the editor lets a program Printf to it meanwhile.
+func (ed *lineEditor) ReadLine(ctx context.Context) (string, error) {
+	if err := ed.run(ctx); err != nil {
+		return "", err
+	}
+	return ed.Line(), nil
+}
+
+// lineBuffer returns a growable buffer holding def, cut short if it is
+// longer than maxLen.
+func lineBuffer(def string, maxLen int) []byte {
+	size := max(len(def)+1, bufSize)
+	if maxLen > 0 {
+		size = min(size, maxLen+1)
+	}
+	buffer := make([]byte, size)
+	setBuffer(buffer, def)
+	return buffer
+}
+
+// ─────────────────────────────────────────────────────────────
+// The calls Versions Four to Six offer
+// ─────────────────────────────────────────────────────────────
+
+// lineReader is a version built on the editor: its options, and what it
+// adds to the editor (see getline04.go to getline06.go).
+type lineReader struct {
+	lineOptions
+	setup func(ed *lineEditor) // nil if nothing
+}
+
+// GetLine keeps the signature of Versions One to Three, so that main.go
+// needs no edits.  The default value is whatever is already in buffer
+// when the function is called; pre-load it before calling if you want a
+// default.  The line can be no longer than buffer allows.
+func (g lineReader) GetLine(prompt string, buffer []byte) bool {
+	return g.ReadInto(prompt, buffer) == nil
+}
+
+// ReadInto is GetLine saying why it failed (see errors.go).
+func (g lineReader) ReadInto(prompt string, buffer []byte) error {
+	return g.GetLineContext(context.Background(), prompt, buffer)
+}
+
+// GetLineContext is ReadInto that gives up when ctx is cancelled or its
+// deadline passes, taking the prompt off the screen and returning
+// ctx.Err().
+func (g lineReader) GetLineContext(ctx context.Context, prompt string, buffer []byte) error {
+	if len(buffer) < 2 {
+		return ErrInvalidBuffer // safety check
+	}
+	return g.newEditor(prompt, buffer).run(ctx)
+}
+
+// ReadLine is GetLine without the fixed buffer: it starts with def and
+// returns the line, as long as it grows (up to MaxLen, if set).
+func (g lineReader) ReadLine(prompt, def string) (string, error) {
+	return g.ReadLineContext(context.Background(), prompt, def)
+}
+
+// ReadLineContext is ReadLine that gives up when ctx is done, as
+// GetLineContext does.
+func (g lineReader) ReadLineContext(ctx context.Context, prompt, def string) (string, error) {
+	return g.Editor(prompt, def).ReadLine(ctx)
+}
+
+// Editor returns the editor ReadLine uses, for a program that reads
+// keys itself and drives it with Feed and Render, or that runs it with
+// its ReadLine method and prints above the prompt with Printf meanwhile.
+func (g lineReader) Editor(prompt, def string) *lineEditor {
+	ed := g.newEditor(prompt, lineBuffer(def, g.MaxLen))
+	ed.growable = true
+	return ed
+}
+
+func (g lineReader) newEditor(prompt string, buffer []byte) *lineEditor {
+	ed := newLineEditor(g.lineOptions, prompt, buffer)
+	if g.setup != nil {
+		g.setup(ed)
+	}
+	return ed
+}
+
+// dispatch runs the command bound to key.  Printable keys that are not
+// bound to anything insert themselves; other unbound keys beep.  In vi
+// mode viDispatch sees the key first.
+func (ed *lineEditor) dispatch(key int) {
+	if ed.vi != nil && ed.viDispatch(key) {
+		return
+	}
+	m := ed.keys
+	if ed.pending != nil {
+		m, ed.pending = ed.pending, nil
+	}
+
+	b, ok := m.lookup(key)
+	if !ok && m == ed.keys && isSelfInsert(key) {
+		b, ok = keyBinding{command: "self-insert"}, true
+	}
+	switch {
+	case !ok:
+		ed.out.Beep()
+		ed.lastCmd = ""
+	case b.prefix != nil:
+		ed.pending = b.prefix // wait for the rest of the sequence
+	default:
+		ed.key = key
+		commands[b.command](ed)
+		ed.lastCmd = b.command
+	}
+}
+
+// ─────────────────────────────────────────────────────────────
+// Display
+// ─────────────────────────────────────────────────────────────
+
+// draw redraws the input line, or the search that has taken it over.
+func (ed *lineEditor) draw() {
+	if ed.search != nil {
+		ed.search.draw(ed.out)
+		return
+	}
+	ed.redisplay()
+}
+
+// redisplay redraws the input line only and places the cursor.  A line
+// too long for the terminal scrolls sideways, or in wrap mode takes
+// more rows.
+func (ed *lineEditor) redisplay() {
+	lead := ed.prompt + ": "
+	if ed.WrapLines {
+		ed.redisplayWrapped(lead)
+		return
+	}
+	leadWidth := displayWidth([]byte(lead))
+	var text string
+	var col int
+	ed.scroll, text, col = scrollWindow(ed.buffer[:clen(ed.buffer)], ed.cursor, ed.scroll, ed.width-leadWidth)
+
+	fmt.Fprint(ed.out, "\r\033[2K") // clear input line
+	fmt.Fprint(ed.out, lead+text)
+
+	// Move cursor to correct column (in cells, not bytes)
+	fmt.Fprintf(ed.out, "\r\033[%dC", leadWidth+col)
+}
+
+// redisplayWrapped is redisplay for wrap mode: it clears every row the
+// line took last time, draws it afresh and moves back up to the cursor.
+func (ed *lineEditor) redisplayWrapped(lead string) {
+	line := ed.buffer[:clen(ed.buffer)]
+	leadWidth := displayWidth([]byte(lead))
+	text, rows := wrapText(line, leadWidth, ed.width)
+	row, col := wrapPosition(line, ed.cursor, leadWidth, ed.width)
+
+	ed.toFirstRow()
+	fmt.Fprint(ed.out, "\033[J") // clear to end of screen
+	fmt.Fprint(ed.out, lead+text)
+
+	if up := rows - 1 - row; up > 0 {
+		fmt.Fprintf(ed.out, "\033[%dA", up)
+	}
+	fmt.Fprint(ed.out, "\r")
+	if col > 0 {
+		fmt.Fprintf(ed.out, "\033[%dC", col)
+	}
+	ed.rows, ed.cursorRow = rows, row
+}
+
+// withdraw erases the helper bar and the input, leaving the cursor where
+// the bar began.
+func (ed *lineEditor) withdraw() {
+	ed.toFirstRow()
+	fmt.Fprint(ed.out, "\033[1A\r\033[J") // up to the bar and clear from there
+	ed.rows = 0
+}
+
+// toFirstRow moves the cursor to the start of the prompt's row.
+func (ed *lineEditor) toFirstRow() {
+	if ed.cursorRow > 0 {
+		fmt.Fprintf(ed.out, "\033[%dA", ed.cursorRow)
+	}
+	fmt.Fprint(ed.out, "\r")
+	ed.cursorRow = 0
+}
+
+// clearInput erases every row of the input, leaving the cursor at the
+// start of the first.
+func (ed *lineEditor) clearInput() {
+	ed.toFirstRow()
+	fmt.Fprint(ed.out, "\033[J")
+	ed.rows = 0
+}
+
+// toLastRow moves the cursor down to the input's last row, so that
+// whatever is printed after a line break goes below it.
+func (ed *lineEditor) toLastRow() {
+	if down := ed.rows - 1 - ed.cursorRow; down > 0 {
+		fmt.Fprintf(ed.out, "\033[%dB", down)
+		ed.cursorRow += down
+	}
+}
+
+// belowInput moves to a fresh line under the input, for leaving it.
+func (ed *lineEditor) belowInput() {
+	ed.toLastRow()
+	fmt.Fprint(ed.out, "\r\n")
+	ed.rows, ed.cursorRow = 0, 0
+}
+
+// printHelper prints the helper bar on the current line, cut to fit
+// the terminal, and moves to the next, where the input starts afresh.
+func (ed *lineEditor) printHelper() {
+	fmt.Fprint(ed.out, "\r\033[2K") // clear line
+	fmt.Fprint(ed.out, fitHelper(ed.helper(), ed.width))
+	fmt.Fprint(ed.out, "\r\n")
+	ed.rows, ed.cursorRow = 0, 0
+}
+
+// helperItem is an entry in the helper bar: the keys bound to commands,
+// then what they do.
+type helperItem struct {
+	commands []string
+	what     string
+}
+
+var (
+	moveItem    = helperItem{[]string{"backward-char", "forward-char", "beginning-of-line", "end-of-line"}, ""}
+	historyItem = helperItem{[]string{"previous-history", "next-history"}, "history"}
+	deleteItem  = helperItem{[]string{"backward-delete-char", "delete-char"}, ""}
+	cancelItem  = helperItem{[]string{"abort"}, "cancel"}
+	normalItem  = helperItem{[]string{"vi-movement-mode"}, "normal mode"}
+
+	helperItems = []helperItem{
+		moveItem,
+		{[]string{"forward-word", "backward-word"}, "word"},
+		historyItem,
+		{[]string{"reverse-search-history"}, "search"},
+		deleteItem,
+		{[]string{"kill-line", "unix-line-discard", "unix-word-rubout"}, "kill"},
+		{[]string{"yank"}, "yank"},
+		{[]string{"undo"}, "undo"},
+		{[]string{"revert-line"}, "default"},
+		{[]string{"complete"}, "complete"},
+		{[]string{"quoted-insert"}, "quote"},
+		cancelItem,
+		{[]string{"redraw-current-line"}, "redisplay"},
+	}
+	viInsertItems = []helperItem{
+		normalItem, moveItem, historyItem, deleteItem,
+		{[]string{"unix-word-rubout", "unix-line-discard"}, "kill"},
+		{[]string{"complete"}, "complete"},
+		cancelItem,
+	}
+	viReplaceItems = []helperItem{
+		normalItem, moveItem, historyItem, deleteItem,
+		{[]string{"overwrite-mode"}, "insert"},
+		{[]string{"complete"}, "complete"},
+		cancelItem,
+	}
+)
+
+// helper returns the text of the helper bar.
+func (ed *lineEditor) helper() string {
+	if ed.vi != nil {
+		return ed.viHelper()
+	}
+	if !ed.insert {
+		return ed.helperBar("REP", helperItems)
+	}
+	return ed.helperBar("INS", helperItems)
+}
+
+// viHelper is the helper bar for vi mode.
+func (ed *lineEditor) viHelper() string {
+	switch {
+	case ed.vi.normal:
+		return "[NORMAL] h l w b e 0 ^ $ move | x X D C | d/c + motion, dd cc | r R replace | p P put | . repeat | u Ctrl-R undo/redo | i a I A insert | j k history | Enter accept"
+	case !ed.insert:
+		return ed.helperBar("REPLACE", viReplaceItems)
+	default:
+		return ed.helperBar("INSERT", viInsertItems)
+	}
+}
+
+// helperBar shows mode and then items, with the keys they are bound to
+// now.  Items with no keys bound are left out.
+func (ed *lineEditor) helperBar(mode string, items []helperItem) string {
+	bar := "[" + mode + "]"
+	sep := " "
+	for _, item := range items {
+		var labels []string
+		for _, command := range item.commands {
+			if label := ed.keyLabel(command); label != "" {
+				labels = append(labels, label)
+			}
+		}
+		if labels == nil {
+			continue
+		}
+		bar += sep + strings.TrimSpace(joinLabels(labels)+" "+item.what)
+		sep = " | "
+	}
+	return bar
+}
+
+// joinLabels joins key labels, as "Ctrl-K/U/W" when they differ only in
+// a last character after the same modifiers.
+func joinLabels(labels []string) string {
+	prefix := labels[0][:strings.LastIndex(labels[0], "-")+1]
+	short := len(labels) > 1 && prefix != ""
+	var lasts []string
+	for _, label := range labels {
+		last, ok := strings.CutPrefix(label, prefix)
+		short = short && ok && utf8.RuneCountInString(last) == 1
+		lasts = append(lasts, last)
+	}
+	if short {
+		return prefix + strings.Join(lasts, "/")
+	}
+	return strings.Join(labels, " ")
+}
+
+// refreshHelper redraws the helper bar above the input line, leaving
+// the cursor at the start of the input for redisplay.
+func (ed *lineEditor) refreshHelper() {
+	ed.toFirstRow()
+	fmt.Fprint(ed.out, "\033[1A") // up to the bar
+	ed.printHelper()
+}
+
+// keyLabel names the key sequence bound to command, for the helper bar,
+// or returns "" if there is none.
+func (ed *lineEditor) keyLabel(command string) string {
+	seq, ok := ed.keys.keyFor(command)
+	if !ok {
+		return ""
+	}
+	label := ""
+	for i, key := range seq {
+		if i > 0 {
+			label += " "
+		}
+		label += keyName(key)
+	}
+	return label
+}
+
+// ─────────────────────────────────────────────────────────────
+// Helpers for commands
+// ─────────────────────────────────────────────────────────────
+
+// startTyping clears the default the first time the user types over it.
+func (ed *lineEditor) startTyping() {
+	if !ed.wasKey {
+		ed.buffer[0] = 0
+		ed.cursor = 0
+		ed.wasKey = true
+	}
+}
+
+// reserve makes room for n more bytes in a growable buffer, as far as
+// MaxLen allows.  A caller's buffer stays the size it is, and commands
+// beep when it is full.
+func (ed *lineEditor) reserve(n int) {
+	size := clen(ed.buffer) + n + 1
+	if !ed.growable || size <= len(ed.buffer) {
+		return
+	}
+	size = max(size, 2*len(ed.buffer))
+	if ed.MaxLen > 0 {
+		size = min(size, ed.MaxLen+1)
+	}
+	if size > len(ed.buffer) {
+		ed.buffer = append(ed.buffer, make([]byte, size-len(ed.buffer))...)
+	}
+}
+
+// setLine replaces the whole line and puts the cursor at its end.
+func (ed *lineEditor) setLine(line string) {
+	ed.reserve(len(line) - clen(ed.buffer))
+	ed.cursor = setBuffer(ed.buffer, line)
+	ed.wasKey = true
+}
+
+// check beeps if a command could not do its job.
+func (ed *lineEditor) check(ok bool) {
+	if !ok {
+		ed.out.Beep()
+	}
+}
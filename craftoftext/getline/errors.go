@@ -0,0 +1,54 @@
+// errors.go
+//
+// Why a line was not read.
+//
+// ReadLine and ReadInto (Versions Four to Six) say why they gave up, so
+// a caller can tell the user abandoning a line from the user finishing
+// altogether, or from the terminal going away.  GetLine's false covers
+// them all.
+
+package main
+
+import (
+	"errors"
+	"io"
+)
+
+var (
+	// ErrCancelled is returned when the user abandons the line with
+	// Ctrl-G (abort).  Asking again is reasonable.
+	ErrCancelled = errors.New("getline: cancelled")
+
+	// ErrInterrupted is returned when the user types Ctrl-C
+	// (interrupt), which in raw mode is a key rather than a signal.
+	ErrInterrupted = errors.New("getline: interrupted")
+
+	// ErrInvalidBuffer is returned by ReadInto for a buffer too small
+	// to hold any line (under 2 bytes).
+	ErrInvalidBuffer = errors.New("getline: buffer too small")
+)
+
+// io.EOF is returned for Ctrl-D on an empty line (end-of-file) and when
+// the terminal's input ends.
+
+// TermError is a failure to use the terminal.
+type TermError struct {
+	Op  string // "raw mode", "read" or "write"
+	Err error
+}
+
+func (e *TermError) Error() string {
+	return "getline: terminal " + e.Op + ": " + e.Err.Error()
+}
+
+func (e *TermError) Unwrap() error {
+	return e.Err
+}
+
+// readError is the error for a failed read from the terminal.
+func readError(err error) error {
+	if err == io.EOF {
+		return io.EOF
+	}
+	return &TermError{"read", err}
+}
@@ -0,0 +1,150 @@
+// filecomplete.go
+//
+// A ready-made Completer for prompts that ask for a file path.
+//
+// The word under the cursor is taken as a path: a leading ~ stands for
+// the home directory, directories complete with a trailing slash, and
+// names containing spaces or quotes come back backslash-escaped (or
+// inside the quotes the user opened).  A directory that cannot be read
+// just yields no candidates.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type fileCompleter struct{}
+
+func (fileCompleter) Complete(line string, cursor int) ([]string, int, int) {
+	start, quote := wordStart(line[:cursor])
+	typed := unescapePath(line[start:cursor])
+
+	// Split into the directory as typed and the name being completed.
+	dir, base := "", typed
+	if i := strings.LastIndexByte(typed, '/'); i >= 0 {
+		dir, base = typed[:i+1], typed[i+1:]
+	} else if typed == "~" {
+		return []string{quotePath("~/", quote, true)}, start, cursor
+	}
+
+	readDir := expandHome(dir)
+	if readDir == "" {
+		readDir = "."
+	}
+	entries, _ := os.ReadDir(readDir) // unreadable → whatever was read, maybe nothing
+
+	var cands []string
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasPrefix(name, base) {
+			continue
+		}
+		if strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".") {
+			continue // hidden unless asked for
+		}
+		isDir := e.IsDir()
+		if e.Type()&os.ModeSymlink != 0 {
+			if fi, err := os.Stat(filepath.Join(readDir, name)); err == nil {
+				isDir = fi.IsDir()
+			}
+		}
+		path := dir + name
+		if isDir {
+			path += "/"
+		}
+		cands = append(cands, quotePath(path, quote, isDir))
+	}
+	return cands, start, cursor
+}
+
+// Display shows listed candidates by their last path element only.
+func (fileCompleter) Display(candidate string) string {
+	p := unescapePath(candidate)
+	slash := strings.HasSuffix(p, "/")
+	p = filepath.Base(strings.TrimSuffix(p, "/"))
+	if slash {
+		p += "/"
+	}
+	return p
+}
+
+// wordStart finds where the path being typed begins: just after the
+// last space that is neither backslash-escaped nor inside quotes.  It
+// also returns the quote character still open at the end, if any.
+func wordStart(s string) (start int, quote byte) {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && quote != '\'':
+			i++ // skip the escaped character
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ' ' || c == '\t':
+			start = i + 1
+		}
+	}
+	return start, quote
+}
+
+// unescapePath removes the quoting added by quotePath (or typed by the
+// user), giving the path as the file system sees it.
+func unescapePath(s string) string {
+	var sb strings.Builder
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && quote != '\'' && i+1 < len(s):
+			i++
+			sb.WriteByte(s[i])
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+// quotePath prepares path for insertion.  Inside an open quote the path
+// is wrapped in that quote, closed unless more can follow (a directory);
+// otherwise awkward characters are escaped with a backslash.
+func quotePath(path string, quote byte, isDir bool) string {
+	if quote != 0 {
+		q := string(quote)
+		if !isDir {
+			return q + path + q
+		}
+		return q + path
+	}
+	var sb strings.Builder
+	for i := 0; i < len(path); i++ {
+		switch path[i] {
+		case ' ', '\t', '\\', '"', '\'':
+			sb.WriteByte('\\')
+		}
+		sb.WriteByte(path[i])
+	}
+	return sb.String()
+}
+
+// expandHome replaces a leading ~ with the user's home directory.
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return home + path[1:]
+}
@@ -0,0 +1,101 @@
+// filecomplete_test.go
+//
+// Tests of the file-name Completer against a small directory tree:
+// escaping, quotes the user opened, hidden files and symlinks.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestFileComplete(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"my file.txt", "notes.txt", ".hidden", "docs/a.md"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("docs", filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+
+	tests := []struct {
+		line  string
+		cands []string
+		start int
+	}{
+		{"cat ", []string{"docs/", "link/", `my\ file.txt`, "notes.txt"}, 4},
+		{"cat my", []string{`my\ file.txt`}, 4},
+		{`cat my\ f`, []string{`my\ file.txt`}, 4},
+		{`cat "my`, []string{`"my file.txt"`}, 4},
+		{`cat "my f`, []string{`"my file.txt"`}, 4},
+		{`cat 'do`, []string{`'docs/`}, 4}, // a directory leaves the quote open
+		{"cat .", []string{".hidden"}, 4},
+		{"cat n", []string{"notes.txt"}, 4},
+		{"cat li", []string{"link/"}, 4},
+		{"cat link/", []string{"link/a.md"}, 4},
+		{"cat nope/", nil, 4},
+		{"cat x", nil, 4},
+		{"cat ~", []string{"~/"}, 4},
+	}
+	for _, tt := range tests {
+		cands, start, end := fileCompleter{}.Complete(tt.line, len(tt.line))
+		if !slices.Equal(cands, tt.cands) || start != tt.start || end != len(tt.line) {
+			t.Errorf("Complete(%q) = %q, %d, %d; want %q, %d, %d",
+				tt.line, cands, start, end, tt.cands, tt.start, len(tt.line))
+		}
+	}
+}
+
+func TestWordStart(t *testing.T) {
+	tests := []struct {
+		s     string
+		start int
+		quote byte
+	}{
+		{"ls", 0, 0},
+		{"ls a", 3, 0},
+		{`ls a\ b`, 3, 0},
+		{`ls "a b`, 3, '"'},
+		{`ls "a b" c`, 9, 0},
+		{`ls 'a\'`, 3, 0}, // no escapes inside single quotes
+	}
+	for _, tt := range tests {
+		start, quote := wordStart(tt.s)
+		if start != tt.start || quote != tt.quote {
+			t.Errorf("wordStart(%q) = %d, %q; want %d, %q", tt.s, start, quote, tt.start, tt.quote)
+		}
+	}
+}
+
+func TestQuotePath(t *testing.T) {
+	tests := []struct {
+		path   string
+		quote  byte
+		isDir  bool
+		quoted string
+	}{
+		{"plain", 0, false, "plain"},
+		{`a b"c'd\e`, 0, false, `a\ b\"c\'d\\e`},
+		{"a b", '"', false, `"a b"`},
+		{"a b/", '\'', true, `'a b/`},
+	}
+	for _, tt := range tests {
+		q := quotePath(tt.path, tt.quote, tt.isDir)
+		if q != tt.quoted {
+			t.Errorf("quotePath(%q, %q, %v) = %q, want %q", tt.path, tt.quote, tt.isDir, q, tt.quoted)
+		}
+		if p := unescapePath(q); p != tt.path {
+			t.Errorf("unescapePath(%q) = %q, want %q", q, p, tt.path)
+		}
+	}
+}
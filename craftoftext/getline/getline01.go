@@ -10,35 +10,47 @@ package main
 import (
 	"fmt"
 	"unicode"
+	"unicode/utf8"
 )
 
-type getlineV1 struct{}
+type getlineV1 struct {
+	Term Terminal // nil = stdin/stdout
+}
 
 func (g getlineV1) GetLine(prompt string, buffer []byte) bool {
 	if len(buffer) < 2 {
 		return false // safety check
 	}
+	t := orStdTerminal(g.Term)
+	if t.MakeRaw() != nil {
+		return false // no raw mode, no keys one at a time
+	}
+	defer t.Restore()
 
-	fmt.Printf("%s: ", prompt)
+	fmt.Fprintf(t, "%s: ", prompt)
 
 	pos := 0
 	for {
-		key := keyGet()
+		key, err := t.ReadKey()
+		if err != nil {
+			return false // input closed
+		}
 
 		if unicode.IsPrint(rune(key)) {
-			if pos >= len(buffer)-1 {
-				beep() // buffer full
+			if n := utf8.RuneLen(rune(key)); pos+n >= len(buffer) {
+				t.Beep() // buffer full
 			} else {
-				buffer[pos] = byte(key)
-				pos++
-				fmt.Printf("%c", key)
+				pos += utf8.EncodeRune(buffer[pos:], rune(key))
+				fmt.Fprintf(t, "%c", key)
 			}
 		} else if key == keyEnter {
 			buffer[pos] = 0 // NUL-terminate
-			fmt.Println()
+			fmt.Fprint(t, "\r\n")
 			return true
+		} else if key == keyResize {
+			// nothing shown depends on the width
 		} else {
-			beep() // unknown key
+			t.Beep() // unknown key
 		}
 	}
 }
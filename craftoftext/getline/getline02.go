@@ -10,45 +10,59 @@ package main
 import (
 	"fmt"
 	"unicode"
+	"unicode/utf8"
 )
 
-type getlineV2 struct{}
+type getlineV2 struct {
+	Term Terminal // nil = stdin/stdout
+}
 
 func (g getlineV2) GetLine(prompt string, buffer []byte) bool {
 	if len(buffer) < 2 {
 		return false // safety check
 	}
+	t := orStdTerminal(g.Term)
+	if t.MakeRaw() != nil {
+		return false // no raw mode, no keys one at a time
+	}
+	defer t.Restore()
 
-	fmt.Printf("%s: ", prompt)
+	fmt.Fprintf(t, "%s: ", prompt)
 
 	pos := 0
 	for {
-		key := keyGet()
+		key, err := t.ReadKey()
+		if err != nil {
+			return false // input closed
+		}
 
 		if unicode.IsPrint(rune(key)) {
-			if pos >= len(buffer)-1 {
-				beep() // buffer full
+			if n := utf8.RuneLen(rune(key)); pos+n >= len(buffer) {
+				t.Beep() // buffer full
 			} else {
-				buffer[pos] = byte(key)
-				pos++
-				fmt.Printf("%c", key)
+				pos += utf8.EncodeRune(buffer[pos:], rune(key))
+				fmt.Fprintf(t, "%c", key)
 			}
 		} else {
 			switch key {
 			case keyBack:
 				if pos > 0 {
-					pos--
-					fmt.Print("\b \b") // move back, overwrite with space, move back again
+					_, n := utf8.DecodeLastRune(buffer[:pos])
+					pos -= n
+					fmt.Fprint(t, "\b \b") // move back, overwrite with space, move back again
 				}
 				// silently ignore backspace at start of line
 
 			case keyEnter:
 				buffer[pos] = 0 // NUL-terminate
-				fmt.Println()
+				fmt.Fprint(t, "\r\n")
 				return true
 
+			case keyResize:
+				// nothing shown depends on the width
+
 			default:
-				beep() // unknown key
+				t.Beep() // unknown key
 			}
 		}
 	}
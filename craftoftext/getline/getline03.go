@@ -14,36 +14,48 @@ import (
 	"unicode"
 )
 
-type getlineV3 struct{}
+type getlineV3 struct {
+	Term Terminal // nil = stdin/stdout
+}
 
 func (g getlineV3) GetLine(prompt string, buffer []byte) bool {
 	if len(buffer) < 2 {
 		return false // safety check
 	}
+	t := orStdTerminal(g.Term)
+	if t.MakeRaw() != nil {
+		return false // no raw mode, no keys one at a time
+	}
+	defer t.Restore()
 
 	// wasKey: has the user started typing yet?
 	// Until they do, the default (already in buffer) is shown but will
 	// be wiped on the first keystroke.
 	wasKey := false
+	scroll := 0
 
 	for {
-		// Redisplay the whole prompt + current buffer on every iteration.
-		fmt.Print("\r\033[2K") // carriage return, erase line (ANSI)
-		fmt.Printf("%s: %s", prompt, cstring(buffer))
+		// Redisplay the whole prompt + current buffer on every iteration,
+		// scrolled to show the end if it is too long for the terminal.
+		lead := prompt + ": "
+		line := buffer[:clen(buffer)]
+		var text string
+		scroll, text, _ = scrollWindow(line, len(line), scroll, termWidth(t)-displayWidth([]byte(lead)))
+		fmt.Fprint(t, "\r\033[2K") // carriage return, erase line (ANSI)
+		fmt.Fprint(t, lead+text)
 
-		key := keyGet()
+		key, err := t.ReadKey()
+		if err != nil {
+			return false // input closed
+		}
 
 		if unicode.IsPrint(rune(key)) {
 			if !wasKey {
 				buffer[0] = 0 // clear the default
 				wasKey = true
 			}
-			pos := clen(buffer)
-			if pos >= len(buffer)-1 {
-				beep()
-			} else {
-				buffer[pos] = byte(key)
-				buffer[pos+1] = 0
+			if insertRune(buffer, clen(buffer), rune(key)) == 0 {
+				t.Beep()
 			}
 
 		} else {
@@ -55,15 +67,18 @@ func (g getlineV3) GetLine(prompt string, buffer []byte) bool {
 				}
 				pos := clen(buffer)
 				if pos > 0 {
-					buffer[pos-1] = 0
+					buffer[prevRune(buffer, pos)] = 0
 				}
 
 			case keyEnter:
-				fmt.Println()
+				fmt.Fprint(t, "\r\n")
 				return true
 
+			case keyResize:
+				// redrawn for the new width at the top of the loop
+
 			default:
-				beep()
+				t.Beep()
 			}
 		}
 	}
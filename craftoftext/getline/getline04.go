@@ -5,191 +5,37 @@ package main
 // GetLine Version Four — from "The Craft of Text Editing" by Craig Finseth.
 //
 
-import (
-	"fmt"
-	"unicode"
-)
-
-type getlineV4 struct{}
-
-// GetLine — Version Four.
+// newGetlineV4 returns GetLine Version Four.
 //
 // Adds over Version Three:
 //   - left / right cursor movement (arrow keys)
 //   - Home / End keys
+//   - Word motion (Alt-F / Alt-B, Ctrl-Right / Ctrl-Left)
+//   - History recall (Up / Down) when History is set
 //   - Forward delete (Del key)
 //   - Insert / replace mode toggle (Ctrl-Z)
+//   - Completion (Tab) when Completer is set
 //   - Quote next character literally (Ctrl-P)
-//   - Clear line (Ctrl-U)
+//   - Kill to end / start of line, kill word (Ctrl-K, Ctrl-U, Ctrl-W, Alt-BS, Alt-D)
+//   - Upcase / downcase / capitalize word (Alt-U, Alt-L, Alt-C)
+//   - Transpose characters (Ctrl-T)
+//   - Yank and yank-pop from the kill ring (Ctrl-Y, Alt-Y)
+//   - Undo / redo (Ctrl-_, Ctrl-Alt-_)
 //   - Restore default (Ctrl-R)
+//   - Reverse history search (Ctrl-S)
 //   - Redisplay (Ctrl-L)
 //   - Cancel / abort (Ctrl-G) — returns false
+//   - Interrupt (Ctrl-C), end of input (Ctrl-D on an empty line) — see errors.go
+//   - Giving up when a context is done (GetLineContext, ReadLineContext)
+//   - Vi editing mode when ViMode is set (see vi.go)
+//   - Long lines wrapped onto more rows when WrapLines is set (see display.go)
+//   - Helper bar cut to the terminal width, and all redrawn when it resizes
+//   - An Editor to drive from a program's own event loop, with Feed and Render
+//   - Printf on the Editor, from any goroutine, to print above the prompt
 //
-// The function signature is unchanged from previous versions so that
-// main.go needs no edits.  The default value is whatever is already
-// in buffer when the function is called; pre-load it before calling
-// if you want a default.
-func (g getlineV4) GetLine(prompt string, buffer []byte) bool {
-	if len(buffer) < 2 {
-		return false // safety check
-	}
-
-	// Save original buffer contents so Ctrl-R can restore the default.
-	saved := make([]byte, len(buffer))
-	copy(saved, buffer)
-
-	// cursor — index of the character the cursor sits on (0-based).
-	// wasKey — has the user pressed anything yet?
-	// insert — true = insert mode, false = replace mode.
-	cursor := clen(buffer) // start at end of any pre-loaded default
-	wasKey := false
-	insert := true
-
-	// Print helper bar once before entering the loop
-	printHelper := func() {
-		mode := "INS"
-		if !insert {
-			mode = "REP"
-		}
-		fmt.Print("\r\033[2K") // clear line
-		fmt.Printf("[%s] ← → Home End | BS Del | Ctrl-U clear | Ctrl-R default | Ctrl-P quote | Ctrl-G cancel | Ctrl-L redisplay", mode)
-		fmt.Println()
-	}
-
-	// Print helper bar initially
-	printHelper()
-
-	for {
-		// ── Redisplay input line only ─────────────────────────────────────
-		fmt.Print("\r\033[2K") // clear input line
-		fmt.Printf("%s: %s", prompt, cstring(buffer))
-
-		// Move cursor to correct column
-		col := len(prompt) + 2 + cursor
-		fmt.Printf("\r\033[%dC", col)
-
-		// ── Read key ─────────────────────────────────────────────────────
-		key := keyGetExt()
-
-		// If insert/replace mode changed, redraw helper bar
-		if key == keyInsToggle {
-			insert = !insert
-			// Move cursor up one line, redraw helper, move back down
-			fmt.Print("\033[1A") // up
-			printHelper()
-			fmt.Print("\033[1B") // down
-			continue
-		}
-
-		// ── Printable character ──────────────────────────────────────────
-		if key > 0 && unicode.IsPrint(rune(key)) {
-			if !wasKey {
-				buffer[0] = 0
-				cursor = 0
-				wasKey = true
-			}
-			if insert {
-				if clen(buffer) >= len(buffer)-1 {
-					beep()
-				} else {
-					insertChar(buffer, cursor, byte(key))
-					cursor++
-				}
-			} else {
-				if cursor >= len(buffer)-1 {
-					beep()
-				} else {
-					if cursor == clen(buffer) {
-						buffer[cursor+1] = 0
-					}
-					buffer[cursor] = byte(key)
-					cursor++
-				}
-			}
-			continue
-		}
-
-		// ── Control / special keys ───────────────────────────────────────
-		switch key {
-
-		case keyBack:
-			if !wasKey {
-				buffer[0] = 0
-				cursor = 0
-				wasKey = true
-			}
-			if cursor > 0 {
-				deleteChar(buffer, cursor-1)
-				cursor--
-			}
-
-		case keyDel:
-			if cursor < clen(buffer) {
-				deleteChar(buffer, cursor)
-			} else {
-				beep()
-			}
-
-		case keyLeft:
-			wasKey = true
-			if cursor > 0 {
-				cursor--
-			}
-
-		case keyRight:
-			wasKey = true
-			if cursor < clen(buffer) {
-				cursor++
-			}
-
-		case keyHome:
-			wasKey = true
-			cursor = 0
-
-		case keyEnd:
-			wasKey = true
-			cursor = clen(buffer)
-
-		case keyEnter:
-			fmt.Println()
-			return true
-
-		case keyCtrlG:
-			fmt.Println()
-			return false
-
-		case keyCtrlU:
-			buffer[0] = 0
-			cursor = 0
-			wasKey = true
-
-		case keyCtrlR:
-			copy(buffer, saved)
-			cursor = clen(buffer)
-			wasKey = false
-
-		case keyCtrlP:
-			if !wasKey {
-				buffer[0] = 0
-				cursor = 0
-				wasKey = true
-			}
-			literal := keyGetExt()
-			if clen(buffer) >= len(buffer)-1 {
-				beep()
-			} else {
-				insertChar(buffer, cursor, byte(literal))
-				cursor++
-			}
-
-		case keyCtrlL:
-			// Redisplay helper + input line
-			fmt.Print("\033[1A") // up
-			printHelper()
-			fmt.Print("\033[1B") // down
-
-		default:
-			beep()
-		}
-	}
+// These are the default bindings; lineOptions.Keymap can change them
+// (see keymap.go).  The editing itself lives in editor.go, and the
+// calls it offers are lineReader's, shared with Versions Five and Six.
+func newGetlineV4(opts lineOptions) lineReader {
+	return lineReader{lineOptions: opts}
 }
@@ -6,185 +6,11 @@ package main
 // Ch 1 Question 1 - Modify the latest version of Get_Line to accept only numeric responses. What sort of error messages should be given? (Easy)
 //
 
-import (
-	"fmt"
-	"unicode"
-)
-
-type getlineV5 struct{}
-
-// The function signature is unchanged from previous versions so that
-// main.go needs no edits.  The default value is whatever is already
-// in buffer when the function is called; pre-load it before calling
-// if you want a default.
-func (g getlineV5) GetLine(prompt string, buffer []byte) bool {
-	if len(buffer) < 2 {
-		return false // safety check
-	}
-
-	// Save original buffer contents so Ctrl-R can restore the default.
-	saved := make([]byte, len(buffer))
-	copy(saved, buffer)
-
-	// cursor — index of the character the cursor sits on (0-based).
-	// wasKey — has the user pressed anything yet?
-	// insert — true = insert mode, false = replace mode.
-	cursor := clen(buffer) // start at end of any pre-loaded default
-	wasKey := false
-	insert := true
-
-	// Print helper bar once before entering the loop
-	printHelper := func() {
-		mode := "INS"
-		if !insert {
-			mode = "REP"
-		}
-		fmt.Print("\r\033[2K") // clear line
-		fmt.Printf("[%s] ← → Home End | BS Del | Ctrl-U clear | Ctrl-R default | Ctrl-P quote | Ctrl-G cancel | Ctrl-L redisplay", mode)
-		fmt.Println()
-	}
-
-	// Print helper bar initially
-	printHelper()
-
-	for {
-		// ── Redisplay input line only ─────────────────────────────────────
-		fmt.Print("\r\033[2K") // clear input line
-		fmt.Printf("%s: %s", prompt, cstring(buffer))
-
-		// Move cursor to correct column
-		col := len(prompt) + 2 + cursor
-		fmt.Printf("\r\033[%dC", col)
-
-		// ── Read key ─────────────────────────────────────────────────────
-		key := keyGetExt()
-
-		// If insert/replace mode changed, redraw helper bar
-		if key == keyInsToggle {
-			insert = !insert
-			// Move cursor up one line, redraw helper, move back down
-			fmt.Print("\033[1A") // up
-			printHelper()
-			fmt.Print("\033[1B") // down
-			continue
-		}
-
-		// ── Printable character ──────────────────────────────────────────
-		if key > 0 && unicode.IsPrint(rune(key)) {
-
-			// numeric-only filter
-			if key < '0' || key > '9' {
-				beep()
-				continue
-			}
-
-			if !wasKey {
-				buffer[0] = 0
-				cursor = 0
-				wasKey = true
-			}
-			if insert {
-				if clen(buffer) >= len(buffer)-1 {
-					beep()
-				} else {
-					insertChar(buffer, cursor, byte(key))
-					cursor++
-				}
-			} else {
-				if cursor >= len(buffer)-1 {
-					beep()
-				} else {
-					if cursor == clen(buffer) {
-						buffer[cursor+1] = 0
-					}
-					buffer[cursor] = byte(key)
-					cursor++
-				}
-			}
-			continue
-		}
-
-		// ── Control / special keys ───────────────────────────────────────
-		switch key {
-
-		case keyBack:
-			if !wasKey {
-				buffer[0] = 0
-				cursor = 0
-				wasKey = true
-			}
-			if cursor > 0 {
-				deleteChar(buffer, cursor-1)
-				cursor--
-			}
-
-		case keyDel:
-			if cursor < clen(buffer) {
-				deleteChar(buffer, cursor)
-			} else {
-				beep()
-			}
-
-		case keyLeft:
-			wasKey = true
-			if cursor > 0 {
-				cursor--
-			}
-
-		case keyRight:
-			wasKey = true
-			if cursor < clen(buffer) {
-				cursor++
-			}
-
-		case keyHome:
-			wasKey = true
-			cursor = 0
-
-		case keyEnd:
-			wasKey = true
-			cursor = clen(buffer)
-
-		case keyEnter:
-			fmt.Println()
-			return true
-
-		case keyCtrlG:
-			fmt.Println()
-			return false
-
-		case keyCtrlU:
-			buffer[0] = 0
-			cursor = 0
-			wasKey = true
-
-		case keyCtrlR:
-			copy(buffer, saved)
-			cursor = clen(buffer)
-			wasKey = false
-
-		case keyCtrlP:
-			if !wasKey {
-				buffer[0] = 0
-				cursor = 0
-				wasKey = true
-			}
-			literal := keyGetExt()
-			if clen(buffer) >= len(buffer)-1 {
-				beep()
-			} else {
-				insertChar(buffer, cursor, byte(literal))
-				cursor++
-			}
-
-		case keyCtrlL:
-			// Redisplay helper + input line
-			fmt.Print("\033[1A") // up
-			printHelper()
-			fmt.Print("\033[1B") // down
-
-		default:
-			beep()
-		}
-	}
+// newGetlineV5 returns Version Five: Version Four taking digits only.
+// Anything else typed is refused with a beep.
+func newGetlineV5(opts lineOptions) lineReader {
+	return lineReader{opts, func(ed *lineEditor) {
+		// numeric-only filter
+		ed.filter = func(r rune) bool { return r >= '0' && r <= '9' }
+	}}
 }
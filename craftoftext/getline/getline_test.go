@@ -0,0 +1,688 @@
+// getline_test.go
+//
+// Scripted tests of GetLine Versions One to Six (see harness_test.go).
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func v1(t Terminal) Liner { return getlineV1{Term: t} }
+func v2(t Terminal) Liner { return getlineV2{Term: t} }
+func v3(t Terminal) Liner { return getlineV3{Term: t} }
+func v4(t Terminal) Liner { return newGetlineV4(lineOptions{Term: t}) }
+func v5(t Terminal) Liner { return newGetlineV5(lineOptions{Term: t}) }
+func v6(t Terminal) Liner {
+	return newGetlineV6(lineOptions{Term: t}, []string{"yes", "no", "maybe"})
+}
+func vi(t Terminal) Liner { return newGetlineV4(lineOptions{Term: t, ViMode: true}) }
+
+// v4dash treats '-' as part of a word.
+func v4dash(t Terminal) Liner {
+	dash := func(r rune) bool { return r == '-' || isWordRune(r) }
+	return newGetlineV4(lineOptions{Term: t, WordChars: dash})
+}
+
+func TestGetLine(t *testing.T) {
+	tests := []struct {
+		name   string
+		liner  func(Terminal) Liner
+		def    string
+		script []string
+		ok     bool
+		line   string
+		screen string // must appear on the screen, if set
+		beeps  int
+	}{
+		{"V1 typing", v1, "", []string{"hello\r"}, true, "hello", "Prompt: hello", 0},
+		{"V1 UTF-8", v1, "", []string{"héllo 日本\r"}, true, "héllo 日本", "Prompt: héllo 日本", 0},
+		{"V1 backspace beeps", v1, "", []string{"ab\x7fc\r"}, true, "abc", "", 1},
+		{"V1 end of input", v1, "", []string{"ab"}, false, "ab", "", 0},
+
+		{"V2 backspace", v2, "", []string{"abx\x7fc\r"}, true, "abc", "Prompt: abc", 0},
+		{"V2 backspace UTF-8", v2, "", []string{"aé\x7f\r"}, true, "a", "", 0},
+
+		{"V3 default kept", v3, "default", []string{"\r"}, true, "default", "Prompt: default", 0},
+		{"V3 typing clears default", v3, "default", []string{"new\r"}, true, "new", "Prompt: new", 0},
+		{"V3 arrow beeps", v3, "", []string{"\x1b[D", "x\r"}, true, "x", "", 1},
+
+		{"V4 Ctrl-R restores default", v4, "default", []string{"abc\x12\r"}, true, "default", "Prompt: default", 0},
+		{"V4 replace mode", v4, "default", []string{"\x01\x1aXY\r"}, true, "XYfault", "[REP]", 0},
+		{"V4 insert with arrows", v4, "", []string{"abc\x1b[D\x1b[DX\r"}, true, "aXbc", "Prompt: aXbc", 0},
+		{"V4 Ctrl-Right", v4, "", []string{"one two\x01\x1b[1;5CX\r"}, true, "oneX two", "", 0},
+		{"V4 Ctrl-G cancels", v4, "", []string{"abc\x07"}, false, "abc", "", 0},
+		{"V4 lone Esc beeps", v4, "", []string{"ab", "\x1b", "c\r"}, true, "abc", "", 1},
+		{"V4 undo kill", v4, "", []string{"abc \x17\x1f\r"}, true, "abc ", "", 0},
+		{"V4 end of input", v4, "", []string{"abc"}, false, "abc", "", 0},
+
+		{"V4 kill line", v4, "", []string{"hello world\x01\x1bf\x0b\r"}, true, "hello", "", 0},
+		{"V4 backward kill word", v4, "", []string{"one two\x1b\x7f\r"}, true, "one ", "", 0},
+		{"V4 kill word", v4, "", []string{"one two\x01\x1bd\r"}, true, " two", "", 0},
+		{"V4 kills in a row join", v4, "", []string{"one two three\x1b\x7f\x1b\x7f\x01\x19\r"}, true, "two threeone ", "", 0},
+		{"V4 kills both ways join in order", v4, "", []string{"one two three\x1bb\x1bb\x0b\x1b\x7f\x19\r"}, true, "one two three", "", 0},
+		{"V4 yank pop", v4, "", []string{"one\x1b\x7ftwo\x1b\x7f\x19\x1by\r"}, true, "one", "", 0},
+		{"V4 yank pop wraps round", v4, "", []string{"one\x1b\x7ftwo\x1b\x7f\x19\x1by\x1by\r"}, true, "two", "", 0},
+		{"V4 yank pop needs a yank", v4, "", []string{"one\x1b\x7fab\x1by\r"}, true, "ab", "", 1},
+		{"V4 yank with nothing killed", v4, "", []string{"\x19\r"}, true, "", "", 1},
+
+		{"V4 upcase word", v4, "", []string{"one two\x01\x1bu\r"}, true, "ONE two", "", 0},
+		{"V4 downcase words", v4, "", []string{"ONE TWO\x01\x1bl\x1bl\r"}, true, "one two", "", 0},
+		{"V4 capitalize words", v4, "", []string{"one tWO\x01\x1bc\x1bc\r"}, true, "One Two", "", 0},
+		{"V4 upcase from mid-word", v4, "", []string{"hello\x01\x1b[C\x1b[C\x1bu\r"}, true, "heLLO", "", 0},
+		{"V4 transpose", v4, "", []string{"abc\x1b[D\x14\r"}, true, "acb", "", 0},
+		{"V4 transpose at end", v4, "", []string{"abc\x14\r"}, true, "acb", "", 0},
+		{"V4 transpose UTF-8", v4, "", []string{"aé\x14\r"}, true, "éa", "", 0},
+		{"V4 transpose at start beeps", v4, "", []string{"ab\x01\x14\r"}, true, "ab", "", 1},
+		{"V4 word stops at dash", v4, "", []string{"foo-bar\x1b\x7f\r"}, true, "foo-", "", 0},
+		{"V4 custom word chars", v4dash, "", []string{"foo-bar\x1b\x7f\r"}, true, "", "", 0},
+		{"V4 custom word chars upcase", v4dash, "", []string{"foo-bar baz\x01\x1bu\r"}, true, "FOO-BAR baz", "", 0},
+
+		{"V4 paste", v4, "default", []string{"\x1b[200~a\r\nb\tc\x07\x1b[201~\r"}, true, "a b c", "Prompt: a b c", 0},
+		{"V4 paste undone in one step", v4, "", []string{"x\x1b[200~yz\x1b[201~\x1f\r"}, true, "x", "", 0},
+		{"V4 paste over default undone in one step", v4, "def", []string{"\x1b[200~xy\x1b[201~\x1f\r"}, true, "def", "", 0},
+		{"V4 paste too long", v4, "", []string{"\x1b[200~" + strings.Repeat("ab", 50) + "\x1b[201~\r"}, true, strings.Repeat("ab", 50)[:bufSize-1], "", 1},
+
+		{"V5 digits only", v5, "", []string{"1a2\r"}, true, "12", "Prompt: 12", 1},
+		{"V5 paste filtered", v5, "", []string{"\x1b[200~1a2\x1b[201~\r"}, true, "12", "", 1},
+
+		{"V6 rejects", v6, "", []string{"xx\r", "no\r"}, true, "no", `Invalid response: "xx"`, 1},
+		{"V6 completes", v6, "", []string{"ma\t\r"}, true, "maybe", "Prompt: maybe", 0},
+
+		{"vi dw", vi, "", []string{"hello world", "\x1b", "0dw\r"}, true, "world", "[NORMAL]", 0},
+		{"vi cw and repeat", vi, "", []string{"a b c", "\x1b", "0cwX", "\x1b", "w.\r"}, true, "X X c", "", 0},
+		{"vi repeat paste", vi, "", []string{"x", "\x1b", "i\x1b[200~ab\x1b[201~", "\x1b", ".\r"}, true, "aabbx", "", 0},
+		{"vi insert bar", vi, "", []string{"x\r"}, true, "x", "[INSERT]", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := runScript(t, tt.liner, tt.def, tt.script...)
+			if s.ok != tt.ok || s.line != tt.line {
+				t.Errorf("got %v %q, want %v %q", s.ok, s.line, tt.ok, tt.line)
+			}
+			if tt.screen != "" && !strings.Contains(s.screen(), tt.screen) {
+				t.Errorf("screen does not show %q:\n%s", tt.screen, s.screen())
+			}
+			if s.term.beeps != tt.beeps {
+				t.Errorf("got %d beeps, want %d", s.term.beeps, tt.beeps)
+			}
+		})
+	}
+}
+
+// TestCursorColumn checks the cursor is placed by display width, not
+// bytes, with wide characters on the line.
+func TestCursorColumn(t *testing.T) {
+	term := newScriptTerminal("日本語\x1b[D")
+	buffer := make([]byte, bufSize)
+	v4(term).GetLine("Prompt", buffer)
+
+	if got, want := term.readAt.col, len("Prompt: ")+4; got != want {
+		t.Errorf("cursor at column %d, want %d\n%s", got, want, term.screen.text())
+	}
+}
+
+// TestHelperRedrawnInPlace checks that redrawing the helper bar, as
+// Ctrl-Z does, leaves the input on the row under it.
+func TestHelperRedrawnInPlace(t *testing.T) {
+	s := runScript(t, v4, "", "ab\x1a")
+	rows := strings.Split(s.screen(), "\n")
+	if len(rows) != 2 || !strings.HasPrefix(rows[0], "[REP]") || rows[1] != "Prompt: ab" {
+		t.Errorf("screen shows:\n%s", s.screen())
+	}
+}
+
+// TestResize checks that the helper bar is cut to fit the terminal, and
+// that a change of size redraws it and the input without losing either.
+func TestResize(t *testing.T) {
+	tests := []struct {
+		name   string
+		script []string
+		bar    string // the helper bar as shown
+		input  string // the input row
+		col    int    // cursor column
+	}{
+		{"narrow", []string{"hello"}, "[INS] ← → Home End …", "Prompt: hello", 13},
+		{"narrowed", []string{"hello\x1b[D\x1b[D", resizeTo(30, 10), "X"}, "[INS] ← → Home End …", "Prompt: helXlo", 12},
+		{"widened", []string{"hello", resizeTo(50, 10), "X"}, "[INS] ← → Home End | Alt-f/b word | ↑ ↓ history …", "Prompt: helloX", 14},
+		{"too narrow for an item", []string{"hi", resizeTo(12, 10)}, "[INS] ← → …", "Prompt: hi", 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			term := newScriptTerminal(tt.script...)
+			term.screen = newVT100(30, 10)
+			buffer := make([]byte, bufSize)
+			v4(term).GetLine("Prompt", buffer)
+
+			want := tt.bar + "\n" + tt.input
+			if got := term.screen.text(); got != want {
+				t.Errorf("screen shows:\n%s\nwant:\n%s", got, want)
+			}
+			if term.readAt.col != tt.col {
+				t.Errorf("cursor at column %d, want %d", term.readAt.col, tt.col)
+			}
+		})
+	}
+}
+
+// TestHelperKeys checks that the helper bar shows the keys as bound.
+func TestHelperKeys(t *testing.T) {
+	keys := defaultKeymap()
+	keys.bind([]int{keyCtrlS}, "yank")
+	keys.bind([]int{keyF1 + 2}, "reverse-search-history")
+	keys.bind([]int{keyCtrlK}, "yank")
+	keys.unbind([]int{keyCtrlG}) // abort unbound
+	ed := newLineEditor(lineOptions{Keymap: keys}, "Prompt", make([]byte, bufSize))
+
+	want := "[INS] ← → Home End | Alt-f/b word | ↑ ↓ history | F3 search | BS Del | Ctrl-U/W kill | Ctrl-K yank | " +
+		"Ctrl-_ undo | Ctrl-R default | Tab complete | Ctrl-P quote | Ctrl-L redisplay"
+	if got := ed.helper(); got != want {
+		t.Errorf("helper bar:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// TestUnbind checks that unbinding takes a key, or the last key of a
+// prefix sequence, out of the keymap.
+func TestUnbind(t *testing.T) {
+	keys := defaultKeymap()
+	keys.bind([]int{'q'}, "beginning-of-line")
+	keys.unbind([]int{'q'})                // printable: inserts itself again
+	keys.unbind([]int{keyCtrlG})           // abort
+	keys.unbind([]int{keyCtrlX, keyCtrlU}) // undo, leaving Ctrl-_
+	liner := func(t Terminal) Liner { return newGetlineV4(lineOptions{Term: t, Keymap: keys}) }
+
+	s := runScript(t, liner, "", "aq\x07", "\x18\x15", "\r")
+	if !s.ok || s.line != "aq" || s.term.beeps != 2 {
+		t.Errorf("got %v %q with %d beeps, want true %q with 2", s.ok, s.line, s.term.beeps, "aq")
+	}
+	if b, ok := keys.lookup(keyCtrlUndo); !ok || b.command != "undo" {
+		t.Errorf("Ctrl-_ lost its binding")
+	}
+	if _, ok := keys.keys[keyCtrlX].prefix.lookup(keyCtrlU); ok {
+		t.Errorf("Ctrl-X Ctrl-U still bound")
+	}
+}
+
+// TestFitHelper checks how the helper bar is cut.
+func TestFitHelper(t *testing.T) {
+	const bar = "[INS] a b | c d | e"
+	tests := []struct {
+		width int
+		want  string
+	}{
+		{40, bar},
+		{len(bar) + 1, bar},
+		{len(bar), "[INS] a b | c d …"},
+		{12, "[INS] a b …"},
+		{8, "[INS] …"},
+		{3, "[…"},
+	}
+	for _, tt := range tests {
+		if got := fitHelper(bar, tt.width); got != tt.want {
+			t.Errorf("fitHelper(%d) = %q, want %q", tt.width, got, tt.want)
+		}
+	}
+}
+
+// TestHorizontalScroll checks that a line wider than the terminal is
+// shown as a window around the cursor with overflow markers.
+func TestHorizontalScroll(t *testing.T) {
+	// Typed one key at a time, the window jumps when the cursor would
+	// leave it and then stays put.
+	const long = "abcdefghijklmnopqrstuvwxyz0123456789ABCD" // 40 characters
+	tests := []struct {
+		name  string
+		liner func(Terminal) Liner
+		keys  string
+		row   string // the input line as shown
+		col   int    // cursor column
+	}{
+		{"fits", v4, "short", "Prompt: short", 13},
+		{"at end", v4, long, "Prompt: <vwxyz0123456789ABCD", 28},
+		{"at start", v4, long + "\x01", "Prompt: abcdefghijklmnopqrstu>", 8},
+		{"in middle", v4, long + "\x01" + strings.Repeat("\x06", 25), "Prompt: <klmnopqrstuvwxyz0123>", 24},
+		{"wide characters", v4, strings.Repeat("日", 15), "Prompt: <日日日日日日日日日日", 29},
+		{"V3", v3, long, "Prompt: <vwxyz0123456789ABCD", 28},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			term := newScriptTerminal(tt.keys)
+			term.screen = newVT100(30, 10)
+			tt.liner(term).GetLine("Prompt", make([]byte, bufSize))
+
+			if got := term.screen.line(term.readAt.row); got != tt.row {
+				t.Errorf("shown as %q, want %q", got, tt.row)
+			}
+			if term.readAt.col != tt.col {
+				t.Errorf("cursor at column %d, want %d", term.readAt.col, tt.col)
+			}
+		})
+	}
+}
+
+// TestWrappedLines checks that in wrap mode a long line is folded onto
+// more rows, and that Up and Down move between them.
+func TestWrappedLines(t *testing.T) {
+	const long = "abcdefghijklmnopqrstuvwxyz0123456789ABCD" // 40 characters
+	wrapped := func(t Terminal) Liner { return newGetlineV4(lineOptions{Term: t, WrapLines: true}) }
+	tests := []struct {
+		name  string
+		keys  string
+		rows  []string // the input's rows as shown
+		row   int      // the cursor's row among them
+		col   int      // and column
+		line  string
+		beeps int
+	}{
+		{"fits", "short", []string{"Prompt: short"}, 0, 13, "short", 0},
+		{"two rows", long, []string{"Prompt: abcdefghijklmnopqrstuv", "wxyz0123456789ABCD"}, 1, 18, long, 0},
+		{"exactly full", long[:22], []string{"Prompt: abcdefghijklmnopqrstuv", ""}, 1, 0, long[:22], 0},
+		{"wide character moved down", "a" + strings.Repeat("日", 11), []string{"Prompt: a日日日日日日日日日日", "日"}, 1, 2, "a" + strings.Repeat("日", 11), 0},
+		{"up", long + "\x1b[AX", []string{"Prompt: abcdefghijXklmnopqrstu", "vwxyz0123456789ABCD"}, 0, 19, long[:10] + "X" + long[10:], 0},
+		{"up past the top", long + "\x1b[A\x1b[A", []string{"Prompt: abcdefghijklmnopqrstuv", "wxyz0123456789ABCD"}, 0, 18, long, 1},
+		{"up and down", long + "\x1b[A\x1b[BY", []string{"Prompt: abcdefghijklmnopqrstuv", "wxyz0123456789ABCDY"}, 1, 19, long + "Y", 0},
+		{"shrinks", long + "\x15", []string{"Prompt:"}, 0, 8, "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			term := newScriptTerminal(tt.keys)
+			term.screen = newVT100(30, 20)
+			buffer := make([]byte, bufSize)
+			wrapped(term).GetLine("Prompt", buffer)
+
+			top := term.readAt.row - tt.row
+			for i, want := range tt.rows {
+				if got := term.screen.line(top + i); got != want {
+					t.Errorf("row %d shown as %q, want %q\n%s", i, got, want, term.screen.text())
+				}
+			}
+			if below := term.screen.line(top + len(tt.rows)); below != "" {
+				t.Errorf("left %q below the input", below)
+			}
+			if term.readAt.col != tt.col {
+				t.Errorf("cursor at column %d, want %d", term.readAt.col, tt.col)
+			}
+			if got := cstring(buffer); got != tt.line {
+				t.Errorf("got %q, want %q", got, tt.line)
+			}
+			if term.beeps != tt.beeps {
+				t.Errorf("got %d beeps, want %d", term.beeps, tt.beeps)
+			}
+		})
+	}
+}
+
+// TestReadLine checks the growable form of GetLine.
+func TestReadLine(t *testing.T) {
+	long := strings.Repeat("abcdefghij", 20) // longer than bufSize
+	tests := []struct {
+		name   string
+		maxLen int
+		def    string
+		script []string
+		line   string
+		err    error
+		beeps  int
+	}{
+		{"default kept", 0, "abc", []string{"\r"}, "abc", nil, 0},
+		{"grows", 0, "", []string{long + "\r"}, long, nil, 0},
+		{"grows for a paste", 0, "", []string{"\x1b[200~" + long + "\x1b[201~\r"}, long, nil, 0},
+		{"grows for a yank", 0, "", []string{long[:60] + "\x15\x19\x19\r"}, long[:60] + long[:60], nil, 0},
+		{"long default", 0, long, []string{"\x01X\r"}, "X" + long, nil, 0},
+		{"MaxLen", 5, "", []string{"abcdefg\r"}, "abcde", nil, 2},
+		{"default cut to MaxLen", 3, "abcdefg", []string{"\r"}, "abc", nil, 0},
+		{"cancelled", 0, "", []string{"abc\x07"}, "", ErrCancelled, 0},
+		{"end of input", 0, "", []string{"abc"}, "", io.EOF, 0},
+		{"interrupted", 0, "", []string{"abc\x03"}, "", ErrInterrupted, 0},
+		{"Ctrl-D on an empty line", 0, "", []string{"\x04"}, "", io.EOF, 0},
+		{"Ctrl-D deletes", 0, "", []string{"ab\x01\x04\r"}, "b", nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			term := newScriptTerminal(tt.script...)
+			line, err := newGetlineV4(lineOptions{Term: term, MaxLen: tt.maxLen}).ReadLine("Prompt", tt.def)
+			if line != tt.line || err != tt.err {
+				t.Errorf("got %q, %v; want %q, %v", line, err, tt.line, tt.err)
+			}
+			if term.beeps != tt.beeps {
+				t.Errorf("got %d beeps, want %d", term.beeps, tt.beeps)
+			}
+		})
+	}
+}
+
+// failingTerminal is a scriptTerminal whose writes fail.
+type failingTerminal struct{ *scriptTerminal }
+
+func (failingTerminal) Write(p []byte) (int, error) { return 0, errors.New("broken pipe") }
+
+// TestReadIntoErrors checks the errors that do not come from a key.
+func TestReadIntoErrors(t *testing.T) {
+	term := newScriptTerminal("abc\r")
+	g := newGetlineV4(lineOptions{Term: term})
+	if err := g.ReadInto("Prompt", make([]byte, 1)); err != ErrInvalidBuffer {
+		t.Errorf("small buffer: got %v, want ErrInvalidBuffer", err)
+	}
+
+	term = newScriptTerminal("abc\r")
+	err := newGetlineV4(lineOptions{Term: failingTerminal{term}}).ReadInto("Prompt", make([]byte, bufSize))
+	var te *TermError
+	if !errors.As(err, &te) || te.Op != "write" {
+		t.Errorf("failing writes: got %v, want a write TermError", err)
+	}
+	if term.raw != 0 {
+		t.Errorf("raw mode left unbalanced: %d", term.raw)
+	}
+}
+
+// noRawTerminal is a terminal that cannot go into raw mode.
+type noRawTerminal struct{ *scriptTerminal }
+
+func (noRawTerminal) MakeRaw() error { return errors.New("not a terminal") }
+
+// TestNoRawMode checks that every version gives up if it cannot put the
+// terminal into raw mode.
+func TestNoRawMode(t *testing.T) {
+	for i, liner := range []func(Terminal) Liner{v1, v2, v3, v4, v5, v6} {
+		term := newScriptTerminal("abc\r")
+		if liner(noRawTerminal{term}).GetLine("Prompt", make([]byte, bufSize)) {
+			t.Errorf("V%d: GetLine succeeded without raw mode", i+1)
+		}
+		if rest := term.input.rest(); rest != "abc\r" {
+			t.Errorf("V%d: keys read without raw mode: %q left", i+1, rest)
+		}
+	}
+
+	err := newGetlineV4(lineOptions{Term: noRawTerminal{newScriptTerminal()}}).ReadInto("Prompt", make([]byte, bufSize))
+	var te *TermError
+	if !errors.As(err, &te) || te.Op != "raw mode" {
+		t.Errorf("got %v, want a raw mode TermError", err)
+	}
+}
+
+// TestGetLineContext checks that a done context ends the call, with the
+// prompt taken off the screen and the terminal restored.
+func TestGetLineContext(t *testing.T) {
+	expired, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name   string
+		ctx    func() (context.Context, context.CancelFunc)
+		stale  bool // a cancelRead left over from an earlier call
+		script []string
+		line   string
+		err    error
+	}{
+		{"deadline", func() (context.Context, context.CancelFunc) {
+			return context.WithTimeout(context.Background(), 10*time.Millisecond)
+		}, false, []string{"abc", waitForCancel}, "", context.DeadlineExceeded},
+		{"cancelled while waiting", func() (context.Context, context.CancelFunc) {
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(5*time.Millisecond, cancel)
+			return ctx, cancel
+		}, false, []string{"abc", waitForCancel}, "", context.Canceled},
+		{"cancelled before", func() (context.Context, context.CancelFunc) {
+			return expired, func() {}
+		}, false, nil, "", context.Canceled},
+		{"stale cancel ignored", func() (context.Context, context.CancelFunc) {
+			return context.WithCancel(context.Background())
+		}, true, []string{"ok\r"}, "ok", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := tt.ctx()
+			defer cancel()
+			term := newScriptTerminal(tt.script...)
+			if tt.stale {
+				term.cancelRead()
+			}
+			line, err := newGetlineV4(lineOptions{Term: term}).ReadLineContext(ctx, "Prompt", "")
+			if line != tt.line || err != tt.err {
+				t.Errorf("got %q, %v; want %q, %v", line, err, tt.line, tt.err)
+			}
+			if term.raw != 0 {
+				t.Errorf("raw mode left unbalanced: %d", term.raw)
+			}
+			if err != nil && term.screen.text() != "" {
+				t.Errorf("prompt left on the screen:\n%s", term.screen.text())
+			}
+			if rest := term.input.rest(); rest != "" {
+				t.Errorf("keys left unread: %q", rest)
+			}
+		})
+	}
+}
+
+func TestFeed(t *testing.T) {
+	screen := newVT100(30, 5)
+	ed := newGetlineV4(lineOptions{}).Editor("Name", "")
+	ed.Resize(30)
+	render := func() { screen.Write(ed.Render()) }
+
+	render()
+	if got := screen.line(1); got != "Name:" {
+		t.Errorf("before any key: input row %q, want %q", got, "Name:")
+	}
+
+	steps := []struct {
+		key  int
+		act  action
+		done bool
+	}{
+		{'h', actionNone, false},
+		{'i', actionNone, false},
+		{keyCtrlS, actionBeep, false}, // no history to search
+		{keyResize, actionNone, false},
+		{keyEnter, actionAccept, true},
+		{'x', actionAccept, true}, // ignored once done
+	}
+	for _, s := range steps {
+		act, done := ed.Feed(s.key)
+		if act != s.act || done != s.done {
+			t.Errorf("Feed(%s) = %d, %v; want %d, %v", keyName(s.key), act, done, s.act, s.done)
+		}
+		if s.key == keyCtrlS {
+			render()
+			if got := screen.line(1); got != "Name: hi" {
+				t.Errorf("after %q: input row %q, want %q", "hi", got, "Name: hi")
+			}
+		}
+	}
+	render()
+	if ed.Line() != "hi" || ed.Err() != nil {
+		t.Errorf("got %q, %v; want %q, <nil>", ed.Line(), ed.Err(), "hi")
+	}
+
+	// Abandon takes the helper bar and input off the screen.
+	screen = newVT100(30, 5)
+	ed = newGetlineV4(lineOptions{}).Editor("Name", "abc")
+	ed.Resize(30)
+	render()
+	ed.Abandon(ErrCancelled)
+	render()
+	if ed.Err() != ErrCancelled || screen.text() != "" {
+		t.Errorf("after Abandon: err %v, screen:\n%s", ed.Err(), screen.text())
+	}
+	if act, done := ed.Feed('x'); act != actionAbandon || !done {
+		t.Errorf("Feed after Abandon = %d, %v; want %d, true", act, done, actionAbandon)
+	}
+}
+
+func TestPrintf(t *testing.T) {
+	screen := newVT100(40, 8)
+	ed := newGetlineV4(lineOptions{}).Editor("Name", "abc")
+	ed.Resize(40)
+	screen.Write(ed.Render())
+	ed.Feed(keyLeft)
+	ed.Printf("log %d", 1)
+	ed.Printf("two\nlines\n")
+	screen.Write(ed.Render())
+
+	want := []string{"log 1", "two", "lines"}
+	for row, line := range want {
+		if got := screen.line(row); got != line {
+			t.Errorf("row %d = %q, want %q", row, got, line)
+		}
+	}
+	if bar := screen.line(3); !strings.HasPrefix(bar, "[INS]") {
+		t.Errorf("row 3 = %q, want the helper bar", bar)
+	}
+	if got := screen.line(4); got != "Name: abc" {
+		t.Errorf("row 4 = %q, want %q", got, "Name: abc")
+	}
+	if screen.row != 4 || screen.col != 8 {
+		t.Errorf("cursor at %d,%d; want 4,8", screen.row, screen.col)
+	}
+}
+
+// TestPrintfConcurrent prints from several goroutines while ReadLine
+// runs, before it starts and while it waits for a key.
+func TestPrintfConcurrent(t *testing.T) {
+	const writers, each = 4, 5
+	term := newScriptTerminal("ab", pause, "c\r")
+	ed := newGetlineV4(lineOptions{Term: term}).Editor("Prompt", "")
+
+	var wg sync.WaitGroup
+	for w := range writers {
+		wg.Go(func() {
+			for i := range each {
+				ed.Printf("writer %d message %d", w, i)
+			}
+		})
+	}
+	type result struct {
+		line string
+		err  error
+	}
+	done := make(chan result)
+	go func() {
+		line, err := ed.ReadLine(context.Background())
+		done <- result{line, err}
+	}()
+	<-term.paused
+	wg.Wait()
+	term.resume <- struct{}{}
+	r := <-done
+
+	if r.line != "abc" || r.err != nil {
+		t.Errorf("got %q, %v; want %q, <nil>", r.line, r.err, "abc")
+	}
+	next := make([]int, writers) // the message each writer should print next
+	for row := range writers * each {
+		var w, i int
+		if _, err := fmt.Sscanf(term.screen.line(row), "writer %d message %d", &w, &i); err != nil || i != next[w] {
+			t.Fatalf("row %d = %q, out of order:\n%s", row, term.screen.line(row), term.screen.text())
+		}
+		next[w]++
+	}
+	if bar := term.screen.line(writers * each); !strings.HasPrefix(bar, "[INS]") {
+		t.Errorf("row %d = %q, want the helper bar", writers*each, bar)
+	}
+	if got := term.screen.line(writers*each + 1); got != "Prompt: abc" {
+		t.Errorf("row %d = %q, want %q", writers*each+1, got, "Prompt: abc")
+	}
+	if term.raw != 0 {
+		t.Errorf("raw mode left unbalanced: %d", term.raw)
+	}
+}
+
+// TestStreamTerminal runs GetLine over a pair of pipes, as over an SSH
+// channel, and resizes the far end while it runs.
+func TestStreamTerminal(t *testing.T) {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	defer inW.Close()
+	term := newStreamTerminal(inR, outW)
+
+	var mu sync.Mutex // guards screen
+	screen := newVT100(80, 24)
+	updated := make(chan struct{}, 1)
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, err := outR.Read(buf)
+			mu.Lock()
+			screen.Write(buf[:n])
+			mu.Unlock()
+			select {
+			case updated <- struct{}{}:
+			default:
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	waitFor := func(row int, want string) {
+		t.Helper()
+		timeout := time.After(2 * time.Second)
+		for {
+			mu.Lock()
+			got := screen.line(row)
+			mu.Unlock()
+			if got == want {
+				return
+			}
+			select {
+			case <-updated:
+			case <-timeout:
+				t.Fatalf("row %d = %q, want %q", row, got, want)
+			}
+		}
+	}
+
+	buffer := make([]byte, bufSize)
+	done := make(chan bool)
+	go func() {
+		done <- newGetlineV4(lineOptions{Term: term}).GetLine("Prompt", buffer)
+		outW.Close()
+	}()
+
+	inW.Write([]byte("hello"))
+	waitFor(1, "Prompt: hello")
+	term.setSize(30, 10)
+	waitFor(0, "[INS] ← → Home End …")
+	inW.Write([]byte("\r"))
+
+	if ok := <-done; !ok || cstring(buffer) != "hello" {
+		t.Errorf("got %v, %q; want true, %q", ok, cstring(buffer), "hello")
+	}
+}
+
+// TestCaughtSignals checks which signals GetLine catches while in raw
+// mode: none once the program says it catches them itself.
+func TestCaughtSignals(t *testing.T) {
+	raw.Lock()
+	defer raw.Unlock()
+	saved := raw.programSignals
+	defer func() { raw.programSignals = saved }()
+
+	raw.programSignals = false
+	var want []os.Signal
+	for _, sig := range []os.Signal{os.Interrupt, syscall.SIGTERM} {
+		if !signal.Ignored(sig) {
+			want = append(want, sig)
+		}
+	}
+	if got := caughtSignals(); !slices.Equal(got, want) {
+		t.Errorf("caughtSignals() = %v, want %v", got, want)
+	}
+
+	raw.programSignals = true
+	if got := caughtSignals(); got != nil {
+		t.Errorf("after ProgramHandlesSignals, caughtSignals() = %v, want none", got)
+	}
+}
@@ -2,6 +2,7 @@ module getline
 
 go 1.25.0
 
-require golang.org/x/term v0.40.0
-
-require golang.org/x/sys v0.41.0 // indirect
+require (
+	golang.org/x/sys v0.41.0
+	golang.org/x/term v0.40.0
+)
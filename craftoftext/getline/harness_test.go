@@ -0,0 +1,216 @@
+// harness_test.go
+//
+// A headless harness for running GetLine from a script.
+//
+// scriptTerminal is a Terminal whose keys come from a script and whose
+// output goes to a vt100 screen.  A script is a list of bursts: the
+// bytes of one burst arrive together, as when a terminal sends an escape
+// sequence, and there is a pause between bursts, so a lone Esc is a
+// burst of its own.  When the script runs out ReadKey returns io.EOF.
+// A burst made by resizeTo changes the screen's size instead, a
+// waitForCancel burst waits until cancelRead is called, and a pause
+// burst waits for the test to resume it.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	screenWidth  = 250 // wide enough for the helper bar on one row
+	screenHeight = 24
+)
+
+type scriptTerminal struct {
+	input  scriptInput
+	screen *vt100
+	beeps  int
+	raw    int           // MakeRaw calls not yet restored
+	cancel chan struct{} // a pending cancelRead
+
+	// At a pause burst ReadKey sends on paused, then waits on resume.
+	paused, resume chan struct{}
+
+	// mu guards screen, which Printf writes from other goroutines while
+	// ReadKey looks at the cursor.
+	mu sync.Mutex
+
+	// readAt is the cursor position when the last key was asked for,
+	// i.e. where the user saw it while typing.
+	readAt struct{ row, col int }
+}
+
+func newScriptTerminal(script ...string) *scriptTerminal {
+	t := &scriptTerminal{
+		screen: newVT100(screenWidth, screenHeight),
+		cancel: make(chan struct{}, 1),
+		paused: make(chan struct{}),
+		resume: make(chan struct{}),
+	}
+	for _, burst := range script {
+		t.input.bursts = append(t.input.bursts, []byte(burst))
+	}
+	return t
+}
+
+func (t *scriptTerminal) ReadKey() (int, error) {
+	t.mu.Lock()
+	t.readAt.row, t.readAt.col = t.screen.row, t.screen.col
+	t.mu.Unlock()
+	select {
+	case <-t.cancel:
+		return 0, errReadCancelled
+	default:
+	}
+	if width, height, ok := t.input.resize(); ok {
+		t.screen.resize(width, height)
+		return keyResize, nil
+	}
+	if t.input.waitForCancel() {
+		<-t.cancel
+		return 0, errReadCancelled
+	}
+	if t.input.take(pause) {
+		t.paused <- struct{}{}
+		<-t.resume
+	}
+	return readKey(&t.input)
+}
+
+func (t *scriptTerminal) cancelRead() {
+	select {
+	case t.cancel <- struct{}{}:
+	default:
+	}
+}
+
+// resizeTo returns a script burst that resizes the screen.
+func resizeTo(width, height int) string {
+	return fmt.Sprintf("%s%d %d", resizeBurst, width, height)
+}
+
+const (
+	resizeBurst   = "\x00resize "
+	waitForCancel = "\x00wait"
+	pause         = "\x00pause"
+)
+
+func (t *scriptTerminal) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.screen.Write(p)
+}
+
+func (t *scriptTerminal) Size() (int, int, error) { return t.screen.width, t.screen.height, nil }
+func (t *scriptTerminal) MakeRaw() error          { t.raw++; return nil }
+func (t *scriptTerminal) Restore() error          { t.raw--; return nil }
+func (t *scriptTerminal) Beep()                   { t.beeps++ }
+
+// scriptInput is the byteSource behind scriptTerminal.
+type scriptInput struct {
+	bursts [][]byte
+}
+
+func (s *scriptInput) readByte() (byte, error) {
+	for len(s.bursts) > 0 && len(s.bursts[0]) == 0 {
+		s.bursts = s.bursts[1:] // the pause before the next burst
+	}
+	if len(s.bursts) == 0 {
+		return 0, io.EOF
+	}
+	b := s.bursts[0][0]
+	s.bursts[0] = s.bursts[0][1:]
+	return b, nil
+}
+
+// readByteTimeout times out at the end of a burst, without waiting.
+func (s *scriptInput) readByteTimeout(time.Duration) (byte, bool) {
+	if len(s.bursts) == 0 || len(s.bursts[0]) == 0 {
+		return 0, false
+	}
+	b, _ := s.readByte()
+	return b, true
+}
+
+// peek returns the next burst, skipping the pauses before it.
+func (s *scriptInput) peek() string {
+	for len(s.bursts) > 0 && len(s.bursts[0]) == 0 {
+		s.bursts = s.bursts[1:]
+	}
+	if len(s.bursts) == 0 {
+		return ""
+	}
+	return string(s.bursts[0])
+}
+
+// resize takes a burst made by resizeTo, if it is next, and returns the
+// size in it.
+func (s *scriptInput) resize() (width, height int, ok bool) {
+	b := s.peek()
+	if !strings.HasPrefix(b, resizeBurst) {
+		return 0, 0, false
+	}
+	fmt.Sscanf(b[len(resizeBurst):], "%d %d", &width, &height)
+	s.bursts = s.bursts[1:]
+	return width, height, true
+}
+
+// waitForCancel takes a waitForCancel burst, if it is next.
+func (s *scriptInput) waitForCancel() bool {
+	return s.take(waitForCancel)
+}
+
+// take takes burst, if it is next.
+func (s *scriptInput) take(burst string) bool {
+	if s.peek() != burst {
+		return false
+	}
+	s.bursts = s.bursts[1:]
+	return true
+}
+
+// rest returns the input not yet read.
+func (s *scriptInput) rest() string {
+	var sb strings.Builder
+	for _, b := range s.bursts {
+		sb.Write(b)
+	}
+	return sb.String()
+}
+
+// session is the outcome of running a script.
+type session struct {
+	ok   bool
+	line string // the buffer's contents
+	term *scriptTerminal
+}
+
+// screen returns what the session left on the screen.
+func (s session) screen() string {
+	return s.term.screen.text()
+}
+
+// runScript calls GetLine on the Liner made by newLiner, with def
+// pre-loaded in the buffer and keys taken from script.
+func runScript(t *testing.T, newLiner func(Terminal) Liner, def string, script ...string) session {
+	t.Helper()
+	term := newScriptTerminal(script...)
+	buffer := make([]byte, bufSize)
+	copy(buffer, def)
+
+	ok := newLiner(term).GetLine("Prompt", buffer)
+
+	if term.raw != 0 {
+		t.Errorf("raw mode left unbalanced: %d", term.raw)
+	}
+	if rest := term.input.rest(); rest != "" {
+		t.Errorf("keys left unread: %q", rest)
+	}
+	return session{ok: ok, line: cstring(buffer), term: term}
+}
@@ -0,0 +1,138 @@
+// history.go
+//
+// Command-line history shared by the editing versions (V4 onward).
+//
+// Accepted lines are appended to a history list that Up/Down can walk
+// through.  The list can be loaded from and saved to a plain text file,
+// one entry per line, so it survives between runs.
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+const defaultHistorySize = 500
+
+// history holds previously accepted lines, oldest first.
+type history struct {
+	entries []string
+	max     int // 0 = unlimited
+}
+
+func newHistory(max int) *history {
+	return &history{max: max}
+}
+
+// add records an accepted line.  Empty lines are ignored, and an
+// earlier copy of the same line is dropped so duplicates collapse
+// into the most recent position.
+func (h *history) add(line string) {
+	if h == nil || line == "" {
+		return
+	}
+	for i, e := range h.entries {
+		if e == line {
+			h.entries = append(h.entries[:i], h.entries[i+1:]...)
+			break
+		}
+	}
+	h.entries = append(h.entries, line)
+	if h.max > 0 && len(h.entries) > h.max {
+		h.entries = h.entries[len(h.entries)-h.max:]
+	}
+}
+
+// ─────────────────────────────────────────────────────────────
+// Persistence
+// ─────────────────────────────────────────────────────────────
+
+// load appends the entries stored in path.  A missing file is not an
+// error — it simply means there is no history yet.
+func (h *history) load(path string) error {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		h.add(unescapeHistory(sc.Text()))
+	}
+	return sc.Err()
+}
+
+// save writes all entries to path, replacing its previous contents.
+func (h *history) save(path string) error {
+	var sb strings.Builder
+	for _, e := range h.entries {
+		sb.WriteString(escapeHistory(e))
+		sb.WriteByte('\n')
+	}
+	return os.WriteFile(path, []byte(sb.String()), 0o600)
+}
+
+// A line entered with Ctrl-P can contain a literal newline, so the
+// file escapes newlines and backslashes to keep one entry per line.
+var (
+	historyEscaper   = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	historyUnescaper = strings.NewReplacer(`\\`, `\`, `\n`, "\n")
+)
+
+func escapeHistory(s string) string   { return historyEscaper.Replace(s) }
+func unescapeHistory(s string) string { return historyUnescaper.Replace(s) }
+
+// ─────────────────────────────────────────────────────────────
+// Walking the history during one GetLine call
+// ─────────────────────────────────────────────────────────────
+
+// historyWalk tracks the position in the history while a line is being
+// edited.  It works on its own copy of the entries, so changes made to a
+// recalled line are remembered while walking but only reach the history
+// itself if that line is accepted.
+type historyWalk struct {
+	lines []string // history entries followed by the line being edited
+	pos   int
+}
+
+// walk starts a walk whose newest position holds current.  A nil
+// history gives a walk with nothing to recall.
+func (h *history) walk(current string) *historyWalk {
+	w := &historyWalk{}
+	if h != nil {
+		w.lines = append(w.lines, h.entries...)
+	}
+	w.lines = append(w.lines, current)
+	w.pos = len(w.lines) - 1
+	return w
+}
+
+// prev stores current at the present position and returns the next
+// older line, or false if there is none.
+func (w *historyWalk) prev(current string) (string, bool) {
+	if w.pos == 0 {
+		return "", false
+	}
+	w.lines[w.pos] = current
+	w.pos--
+	return w.lines[w.pos], true
+}
+
+// next stores current at the present position and returns the next
+// newer line, or false if already at the newest.
+func (w *historyWalk) next(current string) (string, bool) {
+	if w.pos == len(w.lines)-1 {
+		return "", false
+	}
+	w.lines[w.pos] = current
+	w.pos++
+	return w.lines[w.pos], true
+}
@@ -0,0 +1,101 @@
+// history_test.go
+//
+// Tests of the history: recall with Up/Down while editing, how accepted
+// lines are recorded, and the file it is saved to between runs.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+const (
+	up   = "\x1b[A"
+	down = "\x1b[B"
+)
+
+func TestHistoryRecall(t *testing.T) {
+	tests := []struct {
+		name    string
+		max     int
+		script  []string
+		line    string
+		beeps   int
+		entries []string // the history afterwards, oldest first
+	}{
+		{"newest", 0, []string{up + "\r"}, "b", 0, []string{"a", "b"}},
+		{"older moves to the end", 0, []string{up + up + "\r"}, "a", 0, []string{"b", "a"}},
+		{"nothing older", 0, []string{up + up + up + "\r"}, "a", 1, []string{"b", "a"}},
+		{"nothing newer", 0, []string{"x" + down + "\r"}, "x", 1, []string{"a", "b", "x"}},
+		{"back to the line being typed", 0, []string{"x" + up + up + down + down + "\r"}, "x", 0, []string{"a", "b", "x"}},
+		{"edit kept while walking", 0, []string{up + "X" + up + down + "\r"}, "bX", 0, []string{"a", "b", "bX"}},
+		{"edit dropped unless accepted", 0, []string{up + "X" + up + "\r"}, "a", 0, []string{"b", "a"}},
+		{"empty line not recorded", 0, []string{"\r"}, "", 0, []string{"a", "b"}},
+		{"size cap", 2, []string{"c\r"}, "c", 0, []string{"b", "c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHistory(tt.max)
+			h.add("a")
+			h.add("b")
+			liner := func(t Terminal) Liner { return newGetlineV4(lineOptions{Term: t, History: h}) }
+
+			s := runScript(t, liner, "", tt.script...)
+			if !s.ok || s.line != tt.line {
+				t.Errorf("got %v, %q; want true, %q", s.ok, s.line, tt.line)
+			}
+			if s.term.beeps != tt.beeps {
+				t.Errorf("beeped %d times, want %d", s.term.beeps, tt.beeps)
+			}
+			if !slices.Equal(h.entries, tt.entries) {
+				t.Errorf("history is %q, want %q", h.entries, tt.entries)
+			}
+		})
+	}
+}
+
+func TestHistoryFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+
+	h := newHistory(0)
+	if err := h.load(path); err != nil || len(h.entries) != 0 {
+		t.Fatalf("loading a missing file: %q, %v; want nothing, no error", h.entries, err)
+	}
+
+	entries := []string{"plain", "two\nlines", `back\slash`, `not\na newline`, `\`}
+	h.entries = entries
+	if err := h.save(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "plain\ntwo\\nlines\nback\\\\slash\nnot\\\\na newline\n\\\\\n"
+	if string(data) != want {
+		t.Errorf("file holds %q, want %q", data, want)
+	}
+
+	h = newHistory(0)
+	if err := h.load(path); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(h.entries, entries) {
+		t.Errorf("loaded %q, want %q", h.entries, entries)
+	}
+
+	// Loading goes through add, so duplicates collapse and the cap holds.
+	if err := os.WriteFile(path, []byte("a\nb\na\nc\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	h = newHistory(2)
+	if err := h.load(path); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a", "c"}; !slices.Equal(h.entries, want) {
+		t.Errorf("loaded %q, want %q", h.entries, want)
+	}
+}
@@ -0,0 +1,32 @@
+//go:build !unix
+
+// input_other.go
+//
+// Reading stdin where poll(2) is not available: an asyncReader (see
+// termio.go) reads it from a goroutine so that reads can time out.
+
+package main
+
+import (
+	"os"
+	"sync"
+)
+
+var stdinInput = sync.OnceValue(func() *asyncReader {
+	return newAsyncReader(os.Stdin)
+})
+
+func stdin() byteSource {
+	return stdinInput()
+}
+
+func cancelStdin() {
+	stdinInput().cancel()
+}
+
+// raiseSignal ends the program as sig's default action would have:
+// without kill(2) the signal cannot be sent again.  Only signals no one
+// else is catching get here (see restoreOnSignal).
+func raiseSignal(sig os.Signal) {
+	os.Exit(1)
+}
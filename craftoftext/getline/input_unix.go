@@ -0,0 +1,161 @@
+//go:build unix
+
+// input_unix.go
+//
+// Reading stdin on Unix.  Input is read in blocks, so a fast paste or a
+// whole escape sequence costs one system call rather than one per byte,
+// and poll(2) lets us wait for input with a timeout, which the
+// escape-sequence decoder needs to tell a lone Esc from the start of a
+// sequence.  The wait for a key also wakes on SIGWINCH, so a change of
+// terminal size is seen while editing, and when cancelled (see
+// readCanceller in termio.go).
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+type stdinSource struct {
+	buf      [256]byte
+	pos, end int // unread bytes are buf[pos:end]
+}
+
+var stdinInput stdinSource
+
+func stdin() byteSource {
+	return &stdinInput
+}
+
+func (s *stdinSource) readByte() (byte, error) {
+	if s.pos == s.end {
+		if err := s.wait(); err != nil {
+			return 0, err
+		}
+		n, err := os.Stdin.Read(s.buf[:])
+		if n <= 0 {
+			return 0, err
+		}
+		s.pos, s.end = 0, n
+	}
+	b := s.buf[s.pos]
+	s.pos++
+	return b, nil
+}
+
+func (s *stdinSource) readByteTimeout(d time.Duration) (byte, bool) {
+	if s.pos < s.end {
+		b, _ := s.readByte()
+		return b, true
+	}
+	fds := []unix.PollFd{{Fd: int32(os.Stdin.Fd()), Events: unix.POLLIN}}
+	for {
+		n, err := unix.Poll(fds, int(d.Milliseconds()))
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil || n == 0 {
+			return 0, false
+		}
+		b, err := s.readByte()
+		return b, err == nil
+	}
+}
+
+// wait blocks until stdin has input, or returns errResized if the
+// terminal changes size first or errReadCancelled if cancelStdin is
+// called.
+func (s *stdinSource) wait() error {
+	winch, cancel := winchPipe(), cancelPipe()
+	fds := []unix.PollFd{ // poll skips a pipe that could not be made (-1)
+		{Fd: int32(os.Stdin.Fd()), Events: unix.POLLIN},
+		{Fd: int32(winch.r), Events: unix.POLLIN},
+		{Fd: int32(cancel.r), Events: unix.POLLIN},
+	}
+	for {
+		_, err := unix.Poll(fds, -1)
+		switch {
+		case err == unix.EINTR:
+			continue
+		case err != nil:
+			return nil // for Read to report
+		case fds[2].Revents&unix.POLLIN != 0:
+			cancel.drain()
+			return errReadCancelled
+		case fds[1].Revents&unix.POLLIN != 0:
+			winch.drain()
+			return errResized
+		}
+		return nil
+	}
+}
+
+// cancelStdin wakes a readByte waiting for input, or the next one to
+// wait, with errReadCancelled.
+func cancelStdin() {
+	cancelPipe().wake()
+}
+
+// raiseSignal sends sig to this process again.  No one else is catching
+// it, so its default action ends the program (see restoreOnSignal).
+func raiseSignal(sig os.Signal) {
+	if s, ok := sig.(syscall.Signal); ok {
+		syscall.Kill(os.Getpid(), s)
+	}
+}
+
+// wakePipe is a pipe that poll(2) watches alongside stdin; a byte
+// written to it wakes a waiting readByte.  Both ends are non-blocking:
+// a wake-up is not worth waiting for, and the reader drains them all.
+type wakePipe struct {
+	r, w int // -1 if the pipe could not be made
+}
+
+func newWakePipe() wakePipe {
+	var p [2]int
+	if err := unix.Pipe(p[:]); err != nil {
+		return wakePipe{-1, -1}
+	}
+	for _, fd := range p {
+		unix.SetNonblock(fd, true)
+		unix.CloseOnExec(fd)
+	}
+	return wakePipe{p[0], p[1]}
+}
+
+func (p wakePipe) wake() {
+	if p.w >= 0 {
+		unix.Write(p.w, []byte{0})
+	}
+}
+
+func (p wakePipe) drain() {
+	var b [16]byte
+	for {
+		if n, _ := unix.Read(p.r, b[:]); n <= 0 {
+			break
+		}
+	}
+}
+
+// winchPipe is woken on every SIGWINCH.
+var winchPipe = sync.OnceValue(func() wakePipe {
+	p := newWakePipe()
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, unix.SIGWINCH)
+	go func() {
+		for range sigs {
+			p.wake()
+		}
+	}()
+	return p
+})
+
+// cancelPipe is woken by cancelStdin.
+var cancelPipe = sync.OnceValue(newWakePipe)
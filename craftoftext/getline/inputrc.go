@@ -0,0 +1,501 @@
+// inputrc.go
+//
+// Key bindings from a GNU readline inputrc file (~/.inputrc).
+//
+// Only the part of the syntax that makes sense for GetLine is
+// understood:
+//
+//	"\C-a": beginning-of-line      # key sequence in quotes
+//	Meta-Rubout: backward-kill-word # or a key name
+//	set editing-mode vi
+//	$if mode=emacs / $if term=xterm / $if getline
+//	$else / $endif / $include ~/.inputrc.local
+//
+// Readline's other variables are accepted and ignored, since a shared
+// ~/.inputrc is full of them.  Anything else — an unknown command or
+// variable, a macro, a line that does not parse — is reported with its
+// file and line number rather than silently ignored, and the rest of
+// the file still applies.
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// inputrcApp is the application name matched by "$if getline".
+const inputrcApp = "getline"
+
+// inputrcError is one problem found in an inputrc file.
+type inputrcError struct {
+	File string
+	Line int
+	Msg  string
+}
+
+func (e *inputrcError) Error() string {
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Msg)
+}
+
+// inputrcPath returns the file readline would read: $INPUTRC, or
+// ~/.inputrc.
+func inputrcPath() string {
+	if p := os.Getenv("INPUTRC"); p != "" {
+		return p
+	}
+	return expandHome("~/.inputrc")
+}
+
+// applyInputrc reads path and applies its bindings to opts, starting
+// from a copy of the current keymap.  A missing file is not an error.
+// It returns every problem found.
+func applyInputrc(path string, opts *lineOptions) []error {
+	rc := &inputrc{
+		keys: opts.bindings().clone(),
+		mode: "emacs",
+		term: os.Getenv("TERM"),
+	}
+	if opts.ViMode {
+		rc.mode = "vi"
+	}
+	if err := rc.parseFile(path, 0); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		rc.errs = append(rc.errs, err)
+	}
+	opts.Keymap = rc.keys
+	opts.ViMode = rc.mode == "vi"
+	return rc.errs
+}
+
+// inputrc holds the state of a parse.
+type inputrc struct {
+	keys *keymap
+	mode string // editing-mode
+	term string
+	errs []error
+
+	// cond has one entry per open $if: whether lines in its current
+	// branch apply.
+	cond []bool
+}
+
+const maxIncludeDepth = 10
+
+func (rc *inputrc) parseFile(path string, depth int) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	open := len(rc.cond)
+	sc := bufio.NewScanner(f)
+	n := 0
+	for sc.Scan() {
+		n++
+		if msg := rc.parseLine(path, sc.Text(), depth); msg != "" {
+			rc.errs = append(rc.errs, &inputrcError{path, n, msg})
+		}
+	}
+	if len(rc.cond) > open {
+		rc.errs = append(rc.errs, &inputrcError{path, n, "missing $endif"})
+		rc.cond = rc.cond[:open]
+	}
+	return sc.Err()
+}
+
+// active reports whether lines apply in the current $if branches.
+func (rc *inputrc) active() bool {
+	for _, c := range rc.cond {
+		if !c {
+			return false
+		}
+	}
+	return true
+}
+
+// parseLine handles one line and returns a message describing what is
+// wrong with it, or "".
+func (rc *inputrc) parseLine(file, line string, depth int) string {
+	line = strings.TrimSpace(line)
+	if line == "" || line[0] == '#' {
+		return ""
+	}
+
+	// ── Conditionals ─────────────────────────────────────────────────
+	if line[0] == '$' {
+		word, arg, _ := strings.Cut(line[1:], " ")
+		arg = strings.TrimSpace(arg)
+		switch word {
+		case "if":
+			ok, msg := rc.test(arg)
+			rc.cond = append(rc.cond, ok)
+			return msg
+		case "else":
+			if len(rc.cond) == 0 {
+				return "$else without $if"
+			}
+			rc.cond[len(rc.cond)-1] = !rc.cond[len(rc.cond)-1]
+		case "endif":
+			if len(rc.cond) == 0 {
+				return "$endif without $if"
+			}
+			rc.cond = rc.cond[:len(rc.cond)-1]
+		case "include":
+			if !rc.active() {
+				return ""
+			}
+			if depth >= maxIncludeDepth {
+				return "$include nested too deeply"
+			}
+			path := expandHome(arg)
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(filepath.Dir(file), path)
+			}
+			if err := rc.parseFile(path, depth+1); err != nil {
+				return fmt.Sprintf("$include: %v", err)
+			}
+		default:
+			return fmt.Sprintf("unknown directive $%s", word)
+		}
+		return ""
+	}
+
+	if !rc.active() {
+		return ""
+	}
+
+	// ── Variables ────────────────────────────────────────────────────
+	if rest, ok := strings.CutPrefix(line, "set "); ok {
+		name, value, _ := strings.Cut(strings.TrimSpace(rest), " ")
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return "set needs a variable and a value"
+		}
+		return rc.set(strings.ToLower(name), value)
+	}
+
+	// ── Key bindings ─────────────────────────────────────────────────
+	var seq []int
+	var rest string
+	var err error
+	if line[0] == '"' {
+		var end int
+		if end, err = closingQuote(line); err == nil {
+			seq, err = parseKeyseq(line[1:end])
+			rest = line[end+1:]
+		}
+	} else {
+		name, after, found := strings.Cut(line, ":")
+		if !found {
+			return "expected a key binding or a set command"
+		}
+		rest = ":" + after
+		var key int
+		key, err = parseKeyname(strings.TrimSpace(name))
+		seq = []int{key}
+	}
+	if err != nil {
+		return err.Error()
+	}
+
+	rest = strings.TrimSpace(rest)
+	if !strings.HasPrefix(rest, ":") {
+		return "missing ':' after key"
+	}
+	target := strings.TrimSpace(rest[1:])
+	switch {
+	case target == "":
+		return "missing command name"
+	case target[0] == '"' || target[0] == '\'':
+		return "macros are not supported"
+	}
+	if i := strings.IndexAny(target, " \t"); i >= 0 {
+		target = target[:i] // readline allows trailing text
+	}
+	if err := rc.keys.bind(seq, target); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// test evaluates the condition of an $if.
+func (rc *inputrc) test(arg string) (bool, string) {
+	name, value, isPair := strings.Cut(arg, "=")
+	switch {
+	case arg == "":
+		return false, "$if needs a condition"
+	case isPair && name == "mode":
+		return value == rc.mode, ""
+	case isPair && name == "term":
+		base, _, _ := strings.Cut(rc.term, "-")
+		return value == rc.term || value == base, ""
+	case isPair:
+		return false, fmt.Sprintf("unsupported $if test %q", name)
+	}
+	return strings.EqualFold(arg, inputrcApp), ""
+}
+
+// set handles "set variable value".
+func (rc *inputrc) set(name, value string) string {
+	switch name {
+	case "editing-mode":
+		switch value {
+		case "emacs", "vi":
+			rc.mode = value
+		default:
+			return fmt.Sprintf("unknown editing mode %q", value)
+		}
+		return ""
+	}
+	if !readlineVariables[name] {
+		return fmt.Sprintf("unknown variable %q", name)
+	}
+	return ""
+}
+
+// readlineVariables are the variables readline knows.  GetLine has no
+// use for them but editing-mode; the rest are set for other programs.
+var readlineVariables = map[string]bool{
+	"active-region-end-color":          true,
+	"active-region-start-color":        true,
+	"bell-style":                       true,
+	"bind-tty-special-chars":           true,
+	"blink-matching-paren":             true,
+	"colored-completion-prefix":        true,
+	"colored-stats":                    true,
+	"comment-begin":                    true,
+	"completion-display-width":         true,
+	"completion-ignore-case":           true,
+	"completion-map-case":              true,
+	"completion-prefix-display-length": true,
+	"completion-query-items":           true,
+	"convert-meta":                     true,
+	"disable-completion":               true,
+	"echo-control-characters":          true,
+	"emacs-mode-string":                true,
+	"enable-active-region":             true,
+	"enable-bracketed-paste":           true,
+	"enable-keypad":                    true,
+	"enable-meta-key":                  true,
+	"expand-tilde":                     true,
+	"history-preserve-point":           true,
+	"history-size":                     true,
+	"horizontal-scroll-mode":           true,
+	"input-meta":                       true,
+	"isearch-terminators":              true,
+	"keymap":                           true,
+	"mark-directories":                 true,
+	"mark-modified-lines":              true,
+	"mark-symlinked-directories":       true,
+	"match-hidden-files":               true,
+	"menu-complete-display-prefix":     true,
+	"meta-flag":                        true,
+	"output-meta":                      true,
+	"page-completions":                 true,
+	"print-completions-horizontally":   true,
+	"revert-all-at-newline":            true,
+	"search-ignore-case":               true,
+	"show-all-if-ambiguous":            true,
+	"show-all-if-unmodified":           true,
+	"show-mode-in-prompt":              true,
+	"skip-completed-text":              true,
+	"vi-cmd-mode-string":               true,
+	"vi-ins-mode-string":               true,
+	"visible-stats":                    true,
+}
+
+// ─────────────────────────────────────────────────────────────
+// Key syntax
+// ─────────────────────────────────────────────────────────────
+
+// closingQuote returns the index of the quote ending the key sequence
+// that starts line.
+func closingQuote(line string) (int, error) {
+	for i := 1; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '"':
+			return i, nil
+		}
+	}
+	return 0, errors.New("unterminated key sequence")
+}
+
+// parseKeyseq turns the inside of a quoted key sequence such as
+// \C-x\C-u or \e[A into keys, decoding escape sequences the way the
+// terminal's would be.
+func parseKeyseq(s string) ([]int, error) {
+	var chars []int
+	for i := 0; i < len(s); i++ {
+		c := int(s[i])
+		if c != '\\' || i+1 == len(s) {
+			chars = append(chars, c)
+			continue
+		}
+		i++
+		switch e := s[i]; e {
+		case 'C', 'M':
+			// \C-x, \M-x, and the two combined in either order.
+			ctrl, meta := false, false
+			for {
+				if i+2 >= len(s) || s[i+1] != '-' {
+					return nil, fmt.Errorf(`incomplete \%c- in key sequence`, s[i])
+				}
+				if s[i] == 'C' {
+					ctrl = true
+				} else {
+					meta = true
+				}
+				i += 2
+				if s[i] != '\\' || i+1 >= len(s) || (s[i+1] != 'C' && s[i+1] != 'M') {
+					break
+				}
+				i++
+			}
+			c := int(s[i])
+			if ctrl {
+				c = ctrlKey(c)
+			}
+			if meta {
+				chars = append(chars, 27)
+			}
+			chars = append(chars, c)
+		case 'e':
+			chars = append(chars, 27)
+		case 'a':
+			chars = append(chars, 7)
+		case 'b':
+			chars = append(chars, 8)
+		case 'd':
+			chars = append(chars, 127)
+		case 'f':
+			chars = append(chars, 12)
+		case 'n':
+			chars = append(chars, 10)
+		case 'r':
+			chars = append(chars, 13)
+		case 't':
+			chars = append(chars, 9)
+		case 'v':
+			chars = append(chars, 11)
+		case 'x':
+			j := i + 1
+			for j < len(s) && j < i+3 && strings.ContainsRune("0123456789abcdefABCDEF", rune(s[j])) {
+				j++
+			}
+			v, err := strconv.ParseUint(s[i+1:j], 16, 8)
+			if err != nil {
+				return nil, fmt.Errorf(`bad \x escape in key sequence`)
+			}
+			chars = append(chars, int(v))
+			i = j - 1
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			j := i
+			for j < len(s) && j < i+3 && s[j] >= '0' && s[j] <= '7' {
+				j++
+			}
+			v, _ := strconv.ParseUint(s[i:j], 8, 8)
+			chars = append(chars, int(v))
+			i = j - 1
+		default:
+			chars = append(chars, int(e)) // \\, \", \' and anything else
+		}
+	}
+
+	// Decode the characters into keys as they would arrive from the
+	// terminal, so "\e[A" means Up and "\M-f" means Alt-F.  Multi-byte
+	// UTF-8 is reassembled first.
+	runes := []rune(string(bytesOf(chars)))
+	var keys []int
+	for len(runes) > 0 {
+		next := func() int {
+			if len(runes) == 0 {
+				return -1
+			}
+			r := runes[0]
+			runes = runes[1:]
+			return int(r)
+		}
+		key := decodeKey(next(), next)
+		if key <= 0 {
+			return nil, fmt.Errorf("unknown escape sequence in %q", s)
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("empty key sequence")
+	}
+	return keys, nil
+}
+
+func bytesOf(chars []int) []byte {
+	b := make([]byte, len(chars))
+	for i, c := range chars {
+		b[i] = byte(c)
+	}
+	return b
+}
+
+// ctrlKey returns the control code for c: C-a is 1, C-? is DEL.
+func ctrlKey(c int) int {
+	if c == '?' {
+		return 127
+	}
+	return c & 0x1F
+}
+
+// keyNames are the symbolic names readline accepts in key names.
+var keyNames = map[string]int{
+	"del": 127, "rubout": 127,
+	"esc": 27, "escape": 27,
+	"lfd": 10, "newline": 10,
+	"ret": 13, "return": 13,
+	"spc": ' ', "space": ' ',
+	"tab": 9,
+}
+
+// parseKeyname parses the key-name form of a binding, such as
+// Control-u, M-DEL or C-M-f.
+func parseKeyname(name string) (int, error) {
+	meta, ctrl := false, false
+modifiers:
+	for {
+		lower := strings.ToLower(name)
+		switch {
+		case strings.HasPrefix(lower, "control-"):
+			ctrl, name = true, name[len("control-"):]
+		case strings.HasPrefix(lower, "c-"):
+			ctrl, name = true, name[2:]
+		case strings.HasPrefix(lower, "meta-"):
+			meta, name = true, name[len("meta-"):]
+		case strings.HasPrefix(lower, "m-"):
+			meta, name = true, name[2:]
+		default:
+			break modifiers
+		}
+	}
+
+	var key int
+	if k, ok := keyNames[strings.ToLower(name)]; ok {
+		key = k
+	} else if r := []rune(name); len(r) == 1 {
+		key = int(r[0])
+	} else {
+		return 0, fmt.Errorf("unknown key name %q", name)
+	}
+	if ctrl {
+		key = ctrlKey(key)
+	}
+	if meta {
+		key |= keyMeta
+	}
+	return key, nil
+}
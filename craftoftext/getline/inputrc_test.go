@@ -0,0 +1,149 @@
+// inputrc_test.go
+//
+// Tests of the inputrc parser: key syntax, conditionals, includes and
+// the diagnostics for what it does not understand.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseKeyseq(t *testing.T) {
+	tests := []struct {
+		seq  string
+		keys []int
+		err  string
+	}{
+		{`\C-a`, []int{keyCtrlA}, ""},
+		{`\C-x\C-u`, []int{keyCtrlX, keyCtrlU}, ""},
+		{`\M-f`, []int{keyMeta | 'f'}, ""},
+		{`\C-\M-f`, []int{keyMeta | keyCtrlF}, ""},
+		{`\M-\C-f`, []int{keyMeta | keyCtrlF}, ""},
+		{`\C-?`, []int{keyBack}, ""},
+		{`\e[A`, []int{keyUp}, ""},
+		{`\e[1;5C`, []int{keyCtrl | keyRight}, ""},
+		{`\t\r`, []int{keyTab, keyEnter}, ""},
+		{`\x41\101`, []int{'A', 'A'}, ""},
+		{`\\\"\'`, []int{'\\', '"', '\''}, ""},
+		{`é`, []int{'é'}, ""},
+		{`\C-`, nil, `incomplete \C- in key sequence`},
+		{`\xZZ`, nil, `bad \x escape in key sequence`},
+		{``, nil, "empty key sequence"},
+	}
+	for _, tt := range tests {
+		keys, err := parseKeyseq(tt.seq)
+		if msg := errString(err); !slices.Equal(keys, tt.keys) || msg != tt.err {
+			t.Errorf("parseKeyseq(%q) = %v, %q; want %v, %q", tt.seq, keys, msg, tt.keys, tt.err)
+		}
+	}
+}
+
+func TestParseKeyname(t *testing.T) {
+	tests := []struct {
+		name string
+		key  int
+		err  string
+	}{
+		{"Control-u", keyCtrlU, ""},
+		{"C-a", keyCtrlA, ""},
+		{"M-DEL", keyMeta | keyBack, ""},
+		{"Meta-Rubout", keyMeta | keyBack, ""},
+		{"C-M-f", keyMeta | keyCtrlF, ""},
+		{"TAB", keyTab, ""},
+		{"SPC", ' ', ""},
+		{"x", 'x', ""},
+		{"Hyper-x", 0, `unknown key name "Hyper-x"`},
+	}
+	for _, tt := range tests {
+		key, err := parseKeyname(tt.name)
+		if msg := errString(err); key != tt.key || msg != tt.err {
+			t.Errorf("parseKeyname(%q) = %d, %q; want %d, %q", tt.name, key, msg, tt.key, tt.err)
+		}
+	}
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func TestApplyInputrc(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, text string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	write("local", `"\C-t": undo
+`)
+	rc := write("inputrc", `# readline settings for everyone
+set bell-style none
+set completion-ignore-case on
+set vi-ins-mode-string "\1ins\2 "
+$if mode=emacs
+"\C-o": yank
+$else
+"\C-o": kill-line
+$endif
+$if mode=vi
+Control-q: abort
+$endif
+$if getline
+M-z: undo
+$endif
+$include local
+"\C-b": no-such-command
+"\C-b
+set no-such-variable on
+"\C-k": "a macro"
+$endif
+`)
+
+	var opts lineOptions
+	errs := applyInputrc(rc, &opts)
+
+	want := []string{
+		rc + `:17: unknown command "no-such-command"`,
+		rc + ":18: unterminated key sequence",
+		rc + `:19: unknown variable "no-such-variable"`,
+		rc + ":20: macros are not supported",
+		rc + ":21: $endif without $if",
+	}
+	var got []string
+	for _, err := range errs {
+		got = append(got, err.Error())
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("errors:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	bindings := []struct {
+		key     int
+		command string
+	}{
+		{15, "yank"},            // Ctrl-O, the $if mode=emacs branch
+		{17, ""},                // Ctrl-Q, not in vi mode
+		{keyMeta | 'z', "undo"}, // $if getline
+		{keyCtrlT, "undo"},      // from the included file
+		{keyCtrlB, "backward-char"},
+		{keyCtrlK, "kill-line"},
+	}
+	for _, tt := range bindings {
+		b, _ := opts.Keymap.lookup(tt.key)
+		if b.command != tt.command {
+			t.Errorf("%s is bound to %q, want %q", keyName(tt.key), b.command, tt.command)
+		}
+	}
+	if opts.ViMode {
+		t.Errorf("ViMode set, want emacs mode")
+	}
+}
@@ -0,0 +1,109 @@
+// isearch.go
+//
+// Incremental reverse history search, in the style of readline's
+// reverse-i-search.  Each character typed narrows the search to the most
+// recent history entry containing the search string; pressing the search
+// key again moves on to older matches.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// reverseSearch is a search in progress.  It takes over the input line,
+// and the editor hands it every key until the user finishes it.
+type reverseSearch struct {
+	h      *history
+	query  string
+	idx    int // entry currently shown
+	at     int // byte offset of the match in it, -1 = none yet
+	failed bool
+}
+
+// newReverseSearch starts a search of h, or returns nil if there is
+// nothing to search.
+func newReverseSearch(h *history) *reverseSearch {
+	if h == nil || len(h.entries) == 0 {
+		return nil
+	}
+	return &reverseSearch{h: h, idx: len(h.entries) - 1, at: -1}
+}
+
+// find looks for the query in entries from..0 and moves to the first hit.
+func (s *reverseSearch) find(from int) bool {
+	for i := from; i >= 0; i-- {
+		if p := strings.Index(s.h.entries[i], s.query); p >= 0 {
+			s.idx, s.at = i, p
+			return true
+		}
+	}
+	return false
+}
+
+// match returns the entry matched so far, if any.
+func (s *reverseSearch) match() string {
+	if s.at < 0 {
+		return ""
+	}
+	return s.h.entries[s.idx]
+}
+
+// draw shows the search on the input line.
+func (s *reverseSearch) draw(t Terminal) {
+	label := "(reverse-i-search)"
+	if s.failed {
+		label = "(failed reverse-i-search)"
+	}
+	match := s.match()
+	lead := fmt.Sprintf("%s`%s': ", label, s.query)
+	fmt.Fprint(t, "\r\033[2K") // clear input line
+	fmt.Fprint(t, lead+match)
+	col := displayWidth([]byte(lead)) + displayWidth([]byte(match[:max(s.at, 0)]))
+	fmt.Fprintf(t, "\r\033[%dC", col)
+}
+
+// key handles one key of the search.  again reports whether the key is
+// bound to the search command, which moves on to an older match.  done
+// is true once the search is over: Enter finishes it with the matched
+// entry and ok; Esc or Ctrl-G give up, and the caller keeps the line it
+// had before the search.
+func (s *reverseSearch) key(t Terminal, key int, again bool) (line string, done, ok bool) {
+	switch {
+	case again:
+		// Older match for the same string.
+		if s.query == "" || s.idx == 0 || !s.find(s.idx-1) {
+			t.Beep()
+		}
+
+	case key > 0 && unicode.IsPrint(rune(key)):
+		s.query += string(rune(key))
+		s.failed = !s.find(s.idx)
+		if s.failed {
+			t.Beep()
+		}
+
+	case key == keyBack:
+		if s.query == "" {
+			t.Beep()
+			break
+		}
+		_, n := utf8.DecodeLastRuneInString(s.query)
+		s.query = s.query[:len(s.query)-n]
+		s.idx, s.at = len(s.h.entries)-1, -1
+		s.failed = s.query != "" && !s.find(s.idx)
+
+	case key == keyEnter:
+		return s.match(), true, s.at >= 0
+
+	case key == 27, key == keyCtrlG, key&keyMeta != 0: // Esc, or Esc run into the next key
+		return "", true, false
+
+	default:
+		t.Beep()
+	}
+	return "", false, false
+}
@@ -0,0 +1,78 @@
+// isearch_test.go
+//
+// Tests of the incremental reverse history search (Ctrl-S).
+
+package main
+
+import "testing"
+
+// searchHistory is the history the search tests look through.
+func searchHistory() *history {
+	h := newHistory(0)
+	for _, e := range []string{"ls -l", "make test", "cat notes", "make all"} {
+		h.add(e)
+	}
+	return h
+}
+
+// Enter ends a search with the match on the line, so accepting it takes
+// a second Enter.
+func TestReverseSearch(t *testing.T) {
+	tests := []struct {
+		name   string
+		script []string
+		line   string
+		beeps  int
+	}{
+		{"newest match", []string{"\x13mak\r\r"}, "make all", 0},
+		{"narrowed", []string{"\x13make t\r\r"}, "make test", 0},
+		{"match inside an entry", []string{"\x13note\r\r"}, "cat notes", 0},
+		{"search key again", []string{"\x13make\x13\r\r"}, "make test", 0},
+		{"nothing older", []string{"\x13make\x13\x13\r\r"}, "make test", 1},
+		{"search key with no string", []string{"\x13\x13\r\r"}, "", 1},
+		{"failed keeps the last match", []string{"\x13max\r\r"}, "make all", 1},
+		{"backspace widens", []string{"\x13make t\x7f\x7f\r\r"}, "make all", 0},
+		{"backspace after a failure", []string{"\x13max\x7fk\r\r"}, "make all", 1},
+		{"backspace with no string", []string{"\x13\x7f\r\r"}, "", 1},
+		{"Enter leaves the match to edit", []string{"\x13cat\rs\r"}, "cat notess", 0},
+		{"Esc restores", []string{"old\x13mak", "\x1b", "\r"}, "old", 0},
+		{"Ctrl-G restores", []string{"old\x13mak\x07\r"}, "old", 0},
+		{"Alt key restores", []string{"old\x13mak\x1bf\r"}, "old", 0},
+		{"other keys beep", []string{"\x13mak\x01\r\r"}, "make all", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := searchHistory()
+			liner := func(t Terminal) Liner { return newGetlineV4(lineOptions{Term: t, History: h}) }
+
+			s := runScript(t, liner, "", tt.script...)
+			if !s.ok || s.line != tt.line {
+				t.Errorf("got %v, %q; want true, %q", s.ok, s.line, tt.line)
+			}
+			if s.term.beeps != tt.beeps {
+				t.Errorf("beeped %d times, want %d", s.term.beeps, tt.beeps)
+			}
+		})
+	}
+}
+
+func TestReverseSearchShown(t *testing.T) {
+	tests := []struct {
+		script string
+		input  string // the input row
+		col    int    // cursor column, at the match
+	}{
+		{"\x13", "(reverse-i-search)`':", 22},
+		{"\x13te", "(reverse-i-search)`te': cat notes", 30},
+		{"\x13max", "(failed reverse-i-search)`max': make all", 32},
+	}
+	for _, tt := range tests {
+		h := searchHistory()
+		liner := func(t Terminal) Liner { return newGetlineV4(lineOptions{Term: t, History: h}) }
+
+		s := runScript(t, liner, "", tt.script) // left searching when the keys run out
+		if got := s.term.screen.line(1); got != tt.input || s.term.readAt.col != tt.col {
+			t.Errorf("%q shows %q at column %d, want %q at %d", tt.script, got, s.term.readAt.col, tt.input, tt.col)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+// keymap.go
+//
+// Table-driven key bindings for the editing versions (V4 onward).
+//
+// A keymap maps keys to the names of editor commands (see commands.go).
+// A key can also lead to a nested keymap, which makes it a prefix: with
+// Ctrl-X bound to a keymap holding Ctrl-U, the two-key sequence Ctrl-X
+// Ctrl-U runs whatever Ctrl-U is bound to there.  Applications copy the
+// default keymap, rebind what they like and pass it in lineOptions.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+type keymap struct {
+	keys map[int]keyBinding
+}
+
+// keyBinding is either a command name or a prefix keymap.
+type keyBinding struct {
+	command string
+	prefix  *keymap
+}
+
+func newKeymap() *keymap {
+	return &keymap{keys: map[int]keyBinding{}}
+}
+
+// bind makes the key sequence seq run command, creating prefix keymaps
+// for all but its last key as needed.  Binding a sequence replaces
+// whatever was bound to it, including a whole prefix keymap.
+func (m *keymap) bind(seq []int, command string) error {
+	if len(seq) == 0 {
+		return fmt.Errorf("empty key sequence")
+	}
+	if _, ok := commands[command]; !ok {
+		return fmt.Errorf("unknown command %q", command)
+	}
+	for _, key := range seq[:len(seq)-1] {
+		b := m.keys[key]
+		if b.prefix == nil {
+			b = keyBinding{prefix: newKeymap()}
+			m.keys[key] = b
+		}
+		m = b.prefix
+	}
+	m.keys[seq[len(seq)-1]] = keyBinding{command: command}
+	return nil
+}
+
+// unbind removes the binding for seq.  A printable key that is unbound
+// goes back to inserting itself (see lineEditor.dispatch).
+func (m *keymap) unbind(seq []int) {
+	for i, key := range seq {
+		b, ok := m.keys[key]
+		if !ok {
+			return
+		}
+		if i == len(seq)-1 {
+			delete(m.keys, key)
+			return
+		}
+		if b.prefix == nil {
+			return
+		}
+		m = b.prefix
+	}
+}
+
+// lookup returns the binding for a single key.
+func (m *keymap) lookup(key int) (keyBinding, bool) {
+	b, ok := m.keys[key]
+	return b, ok
+}
+
+// clone returns a deep copy of m, for rebinding without touching the
+// original.
+func (m *keymap) clone() *keymap {
+	c := newKeymap()
+	for key, b := range m.keys {
+		if b.prefix != nil {
+			b.prefix = b.prefix.clone()
+		}
+		c.keys[key] = b
+	}
+	return c
+}
+
+// keyFor returns a key sequence bound to command, for showing in the
+// helper bar.  It prefers the shortest, then one starting with a named
+// key such as an arrow, then the lowest-numbered keys.
+func (m *keymap) keyFor(command string) ([]int, bool) {
+	var best []int
+	var walk func(m *keymap, prefix []int)
+	walk = func(m *keymap, prefix []int) {
+		keys := make([]int, 0, len(m.keys))
+		for key := range m.keys {
+			keys = append(keys, key)
+		}
+		sort.Ints(keys)
+		for _, key := range keys {
+			seq := append(prefix[:len(prefix):len(prefix)], key)
+			b := m.keys[key]
+			if b.prefix != nil {
+				walk(b.prefix, seq)
+			} else if b.command == command && (best == nil || better(seq, best)) {
+				best = seq
+			}
+		}
+	}
+	walk(m, nil)
+	return best, best != nil
+}
+
+// better reports whether seq is a better key sequence to show than best,
+// which is no later in key order.
+func better(seq, best []int) bool {
+	if len(seq) != len(best) {
+		return len(seq) < len(best)
+	}
+	return isNamedKey(seq[0]) && !isNamedKey(best[0])
+}
+
+// isNamedKey reports whether key is an unmodified named key, such as ←
+// or Home, rather than a control character or a modified key.
+func isNamedKey(key int) bool {
+	return key >= keyLeft && key&(keyMeta|keyCtrl) == 0
+}
+
+// ─────────────────────────────────────────────────────────────
+// Default bindings
+// ─────────────────────────────────────────────────────────────
+
+// defaultBindings are the Emacs-style keys V4 has always had, plus the
+// usual readline ones for the newer commands.
+var defaultBindings = []struct {
+	seq     []int
+	command string
+}{
+	{[]int{keyLeft}, "backward-char"},
+	{[]int{keyRight}, "forward-char"},
+	{[]int{keyCtrlB}, "backward-char"},
+	{[]int{keyCtrlF}, "forward-char"},
+	{[]int{keyMeta | 'f'}, "forward-word"},
+	{[]int{keyCtrl | keyRight}, "forward-word"},
+	{[]int{keyMeta | keyRight}, "forward-word"},
+	{[]int{keyMeta | 'b'}, "backward-word"},
+	{[]int{keyCtrl | keyLeft}, "backward-word"},
+	{[]int{keyMeta | keyLeft}, "backward-word"},
+	{[]int{keyHome}, "beginning-of-line"},
+	{[]int{keyCtrlA}, "beginning-of-line"},
+	{[]int{keyEnd}, "end-of-line"},
+	{[]int{keyCtrlE}, "end-of-line"},
+	{[]int{keyUp}, "previous-history"},
+	{[]int{keyDown}, "next-history"},
+	{[]int{keyCtrlS}, "reverse-search-history"},
+
+	{[]int{keyBack}, "backward-delete-char"},
+	{[]int{keyDel}, "delete-char"},
+	{[]int{keyInsToggle}, "overwrite-mode"},
+	{[]int{keyIns}, "overwrite-mode"},
+	{[]int{keyCtrlP}, "quoted-insert"},
+	{[]int{keyTab}, "complete"},
+	{[]int{keyPasteStart}, "bracketed-paste-begin"},
+
+	{[]int{keyCtrlK}, "kill-line"},
+	{[]int{keyCtrlU}, "unix-line-discard"},
+	{[]int{keyCtrlW}, "unix-word-rubout"},
+	{[]int{keyMeta | keyBack}, "backward-kill-word"},
+	{[]int{keyMeta | 'd'}, "kill-word"},
+	{[]int{keyCtrlY}, "yank"},
+	{[]int{keyMeta | 'y'}, "yank-pop"},
+	{[]int{keyMeta | 'u'}, "upcase-word"},
+	{[]int{keyMeta | 'l'}, "downcase-word"},
+	{[]int{keyMeta | 'c'}, "capitalize-word"},
+	{[]int{keyCtrlT}, "transpose-chars"},
+
+	{[]int{keyCtrlUndo}, "undo"},
+	{[]int{keyCtrlX, keyCtrlU}, "undo"},
+	{[]int{keyCtrlRedo}, "redo"},
+	{[]int{keyCtrlR}, "revert-line"},
+	{[]int{keyCtrlL}, "redraw-current-line"},
+	{[]int{keyEnter}, "accept-line"},
+	{[]int{keyCtrlG}, "abort"},
+	{[]int{keyCtrlC}, "interrupt"},
+	{[]int{keyCtrlD}, "end-of-file"},
+}
+
+// defaultKeymap returns a fresh copy of the default bindings.
+func defaultKeymap() *keymap {
+	m := newKeymap()
+	for _, b := range defaultBindings {
+		if err := m.bind(b.seq, b.command); err != nil {
+			panic(err) // a typo in the table above
+		}
+	}
+	return m
+}
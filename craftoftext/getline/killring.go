@@ -0,0 +1,110 @@
+// killring.go
+//
+// Emacs-style kill ring for the editing versions (V4 onward).
+//
+// Text removed by the kill commands (Ctrl-K, Ctrl-U, Ctrl-W, Alt-BS,
+// Alt-D) is saved on a ring rather than thrown away.  Ctrl-Y yanks the
+// newest kill back in at the cursor, and Alt-Y straight after a yank
+// replaces it with the next older one.  Kills in a row build up a
+// single entry, so killing three words one at a time yanks back as one
+// piece.
+
+package main
+
+const defaultKillRingSize = 30
+
+// killRing holds killed text, oldest first.
+type killRing struct {
+	entries []string
+	max     int
+
+	// The entry last yanked and where it went, for yank-pop.
+	yankIdx            int
+	yankStart, yankEnd int
+}
+
+func newKillRing(max int) *killRing {
+	return &killRing{max: max}
+}
+
+// kill saves text.  If joined is set (the previous command was a kill
+// too) the text is added to the newest entry instead — in front of it
+// when killing backwards, so the entry keeps the buffer's order.
+func (k *killRing) kill(text string, joined, backward bool) {
+	if text == "" {
+		return
+	}
+	if joined && len(k.entries) > 0 {
+		last := &k.entries[len(k.entries)-1]
+		if backward {
+			*last = text + *last
+		} else {
+			*last += text
+		}
+		return
+	}
+	k.entries = append(k.entries, text)
+	if k.max > 0 && len(k.entries) > k.max {
+		k.entries = k.entries[len(k.entries)-k.max:]
+	}
+}
+
+// killRange removes buffer[start:end], saves it on the ring and returns
+// the new cursor.  Text before the cursor counts as a backward kill.
+func (k *killRing) killRange(buffer []byte, start, end, cursor int, joined bool) int {
+	k.kill(string(buffer[start:end]), joined, start < cursor)
+	deleteRange(buffer, start, end)
+	return start
+}
+
+// yank inserts the newest kill at cursor and returns the new cursor.
+func (k *killRing) yank(buffer []byte, cursor int) (int, bool) {
+	k.yankStart, k.yankEnd = cursor, cursor
+	if len(k.entries) == 0 {
+		return cursor, false
+	}
+	k.yankIdx = len(k.entries) - 1
+	text := k.entries[k.yankIdx]
+	if !insertString(buffer, cursor, text) {
+		return cursor, false
+	}
+	k.yankStart, k.yankEnd = cursor, cursor+len(text)
+	return k.yankEnd, true
+}
+
+// yankPop replaces the text just yanked with the next older kill,
+// wrapping round to the newest.  The caller must only use it straight
+// after yank or yankPop.
+func (k *killRing) yankPop(buffer []byte) (int, bool) {
+	if len(k.entries) < 2 {
+		return k.yankEnd, false
+	}
+	idx := (k.yankIdx + len(k.entries) - 1) % len(k.entries)
+	text := k.entries[idx]
+	if !replaceRange(buffer, k.yankStart, k.yankEnd, text) {
+		return k.yankEnd, false
+	}
+	k.yankIdx = idx
+	k.yankEnd = k.yankStart + len(text)
+	return k.yankEnd, true
+}
+
+// longest returns the length of the longest kill, the most a yank can
+// insert.
+func (k *killRing) longest() int {
+	n := 0
+	for _, e := range k.entries {
+		n = max(n, len(e))
+	}
+	return n
+}
+
+// isKillCommand reports whether the named command is one of the kill
+// commands, whose kills join up when they follow one another.
+func isKillCommand(name string) bool {
+	switch name {
+	case "kill-line", "unix-line-discard", "unix-word-rubout", "backward-kill-word", "kill-word":
+		return true
+	}
+	return false
+}
@@ -1,20 +1,61 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	"unicode/utf8"
 )
 
 type Liner interface {
 	GetLine(prompt string, buffer []byte) bool
 }
 
+// LineReader is the Go-native form of Liner, offered by Versions Four to
+// Six: the line comes back as a string as long as it needs to be,
+// starting from def, and failure as an error.  The call gives up when
+// ctx is done.
+type LineReader interface {
+	ReadLineContext(ctx context.Context, prompt, def string) (string, error)
+}
+
+// EditorMaker is offered by Versions Four to Six too: it hands out the
+// editor behind ReadLine, for a program to Printf to while it runs.
+type EditorMaker interface {
+	Editor(prompt, def string) *lineEditor
+}
+
 func main() {
 	version := flag.Int("v", 5, "GetLine version to use (1–6)")
+	histFile := flag.String("history", "", "file to load history from and save it to (V4–V6)")
+	files := flag.Bool("files", false, "complete file names with Tab (V4–V6)")
+	vi := flag.Bool("vi", false, "start in vi editing mode (V4–V6)")
+	wrap := flag.Bool("wrap", false, "wrap long lines onto more rows instead of scrolling (V4–V6)")
+	timeout := flag.Duration("timeout", 0, "give up if no line is entered in this time (V4–V6)")
+	logEvery := flag.Duration("log", 0, "print a log line above the prompt this often while editing (V4–V6)")
 	flag.Parse()
 
+	hist := newHistory(defaultHistorySize)
+	if *histFile != "" {
+		if err := hist.load(*histFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot load history: %v\n", err)
+		}
+	}
+	opts := lineOptions{History: hist, ViMode: *vi, WrapLines: *wrap}
+	if *files {
+		opts.Completer = fileCompleter{}
+	}
+	for _, err := range applyInputrc(inputrcPath(), &opts) {
+		fmt.Fprintf(os.Stderr, "inputrc: %v\n", err)
+	}
+
 	var active Liner
+	allowed := []string{"yes", "no", "maybe"} // for V6
 
 	switch *version {
 	case 1:
@@ -24,40 +65,113 @@ func main() {
 	case 3:
 		active = getlineV3{}
 	case 4:
-		active = getlineV4{}
+		active = newGetlineV4(opts)
 	case 5:
-		active = getlineV5{}
+		active = newGetlineV5(opts)
 	case 6:
-		active = getlineV6{
-			Allowed: []string{"yes", "no", "maybe"},
-		}
+		active = newGetlineV6(opts, allowed)
 	default:
 		fmt.Fprintf(os.Stderr, "Unknown version %d — using V5\n", *version)
-		active = getlineV5{}
+		active = newGetlineV5(opts)
 	}
 
-	buffer := make([]byte, bufSize)
-
 	// Only preload default text for versions 3–5
+	def := ""
 	switch *version {
 	case 3, 4, 5:
-		copy(buffer, "default text")
+		def = "default text"
 	}
 
 	fmt.Println("Get_Line demo — The Craft of Text Editing (Finseth)")
 	fmt.Println()
 
-	// If this is getlineV6, show allowed responses
-	if v6, ok := active.(getlineV6); ok {
-		fmt.Printf("Allowed responses: %v\n", v6.Allowed)
+	// If this is V6, show allowed responses
+	if *version == 6 {
+		fmt.Printf("Allowed responses: %v\n", allowed)
 	}
 
-	ok := active.GetLine("Enter some text", buffer)
-	if !ok {
-		fmt.Fprintln(os.Stderr, "GetLine returned false (cancelled or buffer too small)")
-		os.Exit(1)
+	var line string
+	if r, ok := active.(LineReader); ok {
+		// Catch SIGINT and SIGTERM here rather than in GetLine: they
+		// cancel the line, and GetLine restores the terminal as it
+		// gives up.
+		ProgramHandlesSignals()
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		if *timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, *timeout)
+			defer cancel()
+		}
+		read := r.ReadLineContext
+		if m, ok := active.(EditorMaker); ok && *logEvery > 0 {
+			read = func(ctx context.Context, prompt, def string) (string, error) {
+				ed := m.Editor(prompt, def)
+				defer logTicks(ed, *logEvery)()
+				return ed.ReadLine(ctx)
+			}
+		}
+		var err error
+		for {
+			line, err = read(ctx, "Enter some text", def)
+			if err != ErrCancelled {
+				break
+			}
+			fmt.Println("Cancelled — try again, or Ctrl-D to quit.")
+		}
+		switch {
+		case err == io.EOF:
+			return
+		case err == context.DeadlineExceeded:
+			fmt.Println("Timed out.")
+			os.Exit(1)
+		case err == context.Canceled:
+			fmt.Println("Stopped by a signal.")
+			os.Exit(1)
+		case err == ErrInterrupted:
+			os.Exit(130) // as the shell reports death by SIGINT
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "ReadLine failed: %v\n", err)
+			os.Exit(1)
+		}
+	} else {
+		buffer := make([]byte, bufSize)
+		copy(buffer, def)
+		if !active.GetLine("Enter some text", buffer) {
+			fmt.Fprintln(os.Stderr, "GetLine returned false (cancelled or buffer too small)")
+			os.Exit(1)
+		}
+		line = cstring(buffer)
 	}
 
-	fmt.Printf("\nYou entered : %q\n", cstring(buffer))
-	fmt.Printf("Length      : %d characters\n", len(cstring(buffer)))
+	if *histFile != "" {
+		if err := hist.save(*histFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot save history: %v\n", err)
+		}
+	}
+
+	fmt.Printf("\nYou entered : %q\n", line)
+	fmt.Printf("Length      : %d characters\n", utf8.RuneCountInString(line))
+}
+
+// logTicks prints the time above ed's prompt every d, as a background
+// task logging while the user types would, until the returned function
+// is called.
+func logTicks(ed *lineEditor, d time.Duration) (stop func()) {
+	ticker := time.NewTicker(d)
+	quit := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case now := <-ticker.C:
+				ed.Printf("%s tick", now.Format(time.TimeOnly))
+			case <-quit:
+				return
+			}
+		}
+	}()
+	return func() {
+		ticker.Stop()
+		close(quit)
+	}
 }
@@ -0,0 +1,68 @@
+// options.go
+//
+// Settings shared by the editing versions (V4 onward).  Each of those
+// types embeds lineOptions, so a setting added here is available to all
+// of them; the zero value gives the plain behaviour of the book.
+
+package main
+
+type lineOptions struct {
+	// Term is the terminal to edit on.  If nil, stdin and stdout.
+	Term Terminal
+
+	// History, if set, supplies lines for Up/Down recall and receives
+	// every accepted line.
+	History *history
+
+	// Keymap, if set, replaces the default key bindings.  Start from
+	// defaultKeymap() to change only a few keys; for readline's Ctrl-R
+	// habit, bind keyCtrlR to "reverse-search-history".
+	Keymap *keymap
+
+	// Completer, if set, is consulted when Tab is pressed.
+	Completer Completer
+
+	// KillRing keeps killed text for Ctrl-Y.  Share one between calls
+	// to yank text killed in an earlier line; if nil, each call gets
+	// its own.
+	KillRing *killRing
+
+	// WordChars decides which characters make up a word for the word
+	// commands (Alt-F, Alt-B, Alt-D, Alt-BS, Alt-U/L/C).  If nil,
+	// letters, digits and combining marks do.
+	WordChars func(rune) bool
+
+	// ViMode starts the line in vi insert mode, with Esc leading to vi
+	// normal mode (see vi.go).  The Keymap then applies in insert mode.
+	ViMode bool
+
+	// WrapLines shows a line too long for the terminal on as many rows
+	// as it needs instead of scrolling it sideways.  Up and Down then
+	// move between the rows before they reach the history.
+	WrapLines bool
+
+	// MaxLen, if set, is the longest line in bytes that ReadLine will
+	// take.  GetLine is limited by the size of its buffer instead.
+	MaxLen int
+}
+
+func (o lineOptions) kills() *killRing {
+	if o.KillRing != nil {
+		return o.KillRing
+	}
+	return newKillRing(defaultKillRingSize)
+}
+
+func (o lineOptions) wordChars() func(rune) bool {
+	if o.WordChars != nil {
+		return o.WordChars
+	}
+	return isWordRune
+}
+
+func (o lineOptions) bindings() *keymap {
+	if o.Keymap != nil {
+		return o.Keymap
+	}
+	return defaultKeymap()
+}
@@ -0,0 +1,139 @@
+// rawmode.go
+//
+// Raw mode on the process's own terminal (stdTerminal), for the length
+// of a GetLine call.
+//
+// The terminal goes into raw mode once when GetLine starts and comes
+// back out when it returns — by a deferred call, so a panic restores it
+// too.  A SIGINT or SIGTERM from outside while in raw mode restores the
+// terminal and is then raised again, so that it ends the program as it
+// would have without GetLine, but without leaving the shell with no
+// echo.  (Ctrl-C typed at the keyboard is just a key in raw mode.)
+//
+// Go cannot tell GetLine whether the program is catching these signals
+// as well, and if it were, raising them again would deliver each one to
+// it twice.  A program that catches them calls ProgramHandlesSignals,
+// and GetLine then leaves them alone: the program's handler should end
+// GetLine, by cancelling the context it was given, before the program
+// exits, and the terminal is restored as GetLine returns.  A signal that
+// was ignored when the program started is left alone too.
+//
+// Raw mode here turns off the terminal's output processing as well, so
+// anything printed during GetLine ends its lines with "\r\n".
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"golang.org/x/term"
+)
+
+var raw struct {
+	sync.Mutex
+	depth   int           // enterRaw calls not yet left
+	state   *term.State   // to restore, nil if stdin is not a terminal
+	stopSig chan struct{} // closed to stop restoreOnSignal, nil if not running
+
+	programSignals bool // see ProgramHandlesSignals
+}
+
+// ProgramHandlesSignals tells GetLine that the program catches SIGINT
+// and SIGTERM itself, so that GetLine does not catch them too (see
+// above).  Call it before GetLine.
+func ProgramHandlesSignals() {
+	raw.Lock()
+	raw.programSignals = true
+	raw.Unlock()
+}
+
+// enterRaw puts the terminal into raw mode until the matching leaveRaw.
+// Calls nest; only the outermost pair changes the terminal.  If stdin is
+// not a terminal there is no mode to change, and GetLine carries on
+// reading it as it is.
+func enterRaw() error {
+	raw.Lock()
+	defer raw.Unlock()
+	if raw.depth > 0 {
+		raw.depth++
+		return nil
+	}
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		state, err := term.MakeRaw(fd)
+		if err != nil {
+			return err
+		}
+		raw.state = state
+		if sigs := caughtSignals(); len(sigs) > 0 {
+			raw.stopSig = make(chan struct{})
+			go restoreOnSignal(raw.stopSig, sigs)
+		}
+	}
+	raw.depth++
+	return nil
+}
+
+func leaveRaw() {
+	raw.Lock()
+	defer raw.Unlock()
+	if raw.depth == 0 {
+		return // not in raw mode
+	}
+	raw.depth--
+	if raw.depth == 0 && raw.stopSig != nil {
+		close(raw.stopSig)
+		raw.stopSig = nil
+	}
+	if raw.depth == 0 {
+		restoreTerminal()
+	}
+}
+
+// restoreTerminal puts back the mode the terminal had before enterRaw.
+// The caller holds raw's lock.
+func restoreTerminal() {
+	if raw.state != nil {
+		term.Restore(int(os.Stdin.Fd()), raw.state)
+		raw.state = nil
+	}
+}
+
+// caughtSignals returns the signals restoreOnSignal should catch: none
+// if the program catches them itself, and none that are ignored.  The
+// caller holds raw's lock.
+func caughtSignals() []os.Signal {
+	if raw.programSignals {
+		return nil
+	}
+	var sigs []os.Signal
+	for _, sig := range []os.Signal{os.Interrupt, syscall.SIGTERM} {
+		if !signal.Ignored(sig) {
+			sigs = append(sigs, sig)
+		}
+	}
+	return sigs
+}
+
+// restoreOnSignal waits for one of sigs until stop is closed.  On a
+// signal it restores the terminal, stops catching the signal and raises
+// it again, to end the program as the signal's default action does.
+func restoreOnSignal(stop chan struct{}, sigs []os.Signal) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, sigs...)
+
+	select {
+	case sig := <-c:
+		raw.Lock()
+		restoreTerminal()
+		raw.Unlock()
+		os.Stdout.WriteString("\r\n")
+		signal.Stop(c)
+		raiseSignal(sig)
+	case <-stop:
+		signal.Stop(c)
+	}
+}
@@ -5,11 +5,11 @@
 package main
 
 import (
-	"os"
+	"fmt"
 	"os/exec"
 	"runtime"
-
-	"golang.org/x/term"
+	"unicode"
+	"unicode/utf8"
 )
 
 // ─────────────────────────────────────────────────────────────
@@ -17,90 +17,103 @@ import (
 // ─────────────────────────────────────────────────────────────
 
 const (
+	keyTab   = 9
 	keyEnter = 13
 	keyBack  = 127
 	bufSize  = 80
 
-	keyLeft  = -1
-	keyRight = -2
-	keyHome  = -3
-	keyEnd   = -4
-	keyDel   = -5
-
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
 	keyCtrlG     = 7
+	keyCtrlK     = 11
 	keyCtrlL     = 12
 	keyCtrlP     = 16
 	keyCtrlU     = 21
 	keyCtrlR     = 18
+	keyCtrlS     = 19
+	keyCtrlT     = 20
+	keyCtrlW     = 23
+	keyCtrlX     = 24
+	keyCtrlY     = 25
 	keyInsToggle = 26
+	keyCtrlUndo  = 31 // Ctrl-_
+	keyCtrlRedo  = keyMeta | keyCtrlUndo
 )
 
-// ─────────────────────────────────────────────────────────────
-// Raw key input
-// ─────────────────────────────────────────────────────────────
+// Named keys are numbered above the Unicode range, so a key is either a
+// rune or one of these, and a modifier bit can be ORed onto both.
+const (
+	keyLeft = unicode.MaxRune + 1 + iota
+	keyRight
+	keyHome
+	keyEnd
+	keyDel
+	keyUp
+	keyDown
+	keyPgUp
+	keyPgDn
+	keyIns
+	keyBackTab // Shift-Tab
+	keyF1
+	keyF12 = keyF1 + 11
+
+	// The markers a terminal in bracketed paste mode puts around pasted
+	// text: ESC [ 200 ~ and ESC [ 201 ~.
+	keyPasteStart = keyF12 + 1
+	keyPasteEnd   = keyF12 + 2
+	keyResize     = keyF12 + 3 // not a key: the terminal changed size
+)
 
-func keyGet() int {
-	oldState, err := term.MakeRaw(int(os.Stdin.Fd()))
-	if err != nil {
-		var b [1]byte
-		os.Stdin.Read(b[:])
-		return int(b[0])
-	}
-	defer term.Restore(int(os.Stdin.Fd()), oldState)
+// Modifier bits.  keyMeta marks a key typed with Alt (or after Esc),
+// e.g. keyMeta|'y'; keyCtrl marks a named key typed with Ctrl, e.g.
+// keyCtrl|keyRight.  Ctrl with a letter is just the control code.
+const (
+	keyMeta = 1 << 24
+	keyCtrl = 1 << 25
+)
 
-	var b [1]byte
-	os.Stdin.Read(b[:])
-	return int(b[0])
+// isSelfInsert reports whether key is a printable character, which types
+// itself into the line unless bound to something else.
+func isSelfInsert(key int) bool {
+	return key > 0 && key <= unicode.MaxRune && unicode.IsPrint(rune(key))
 }
 
-// ─────────────────────────────────────────────────────────────
-// Extended key decoder (arrow keys, Home, End, Delete)
-// ─────────────────────────────────────────────────────────────
-
-func keyGetExt() int {
-	ch := keyGet()
-	if ch != 27 { // ESC
-		return ch
+// keyName returns a short human-readable name for key, as shown in the
+// helper bar.
+func keyName(key int) string {
+	if key&keyMeta != 0 {
+		return "Alt-" + keyName(key&^keyMeta)
 	}
-
-	next := keyGet()
-	if next != '[' {
-		return 27
+	if key&keyCtrl != 0 {
+		return "Ctrl-" + keyName(key&^keyCtrl)
 	}
-
-	ch2 := keyGet()
-	switch ch2 {
-	case 'A':
-		return keyHome
-	case 'B':
-		return keyEnd
-	case 'C':
-		return keyRight
-	case 'D':
-		return keyLeft
-	case 'H':
-		return keyHome
-	case 'F':
-		return keyEnd
-	case '1':
-		keyGet()
-		return keyHome
-	case '3':
-		keyGet()
-		return keyDel
-	case '4':
-		keyGet()
-		return keyEnd
-	case '7':
-		keyGet()
-		return keyHome
-	case '8':
-		keyGet()
-		return keyEnd
+	switch {
+	case key == keyEnter:
+		return "Enter"
+	case key == 27:
+		return "Esc"
+	case key == keyTab:
+		return "Tab"
+	case key > 0 && key < 32:
+		return "Ctrl-" + string(rune(key+'@'))
+	case key == keyBack:
+		return "BS"
+	case key >= 0 && key <= unicode.MaxRune:
+		return string(rune(key))
+	case key >= keyLeft && key <= keyBackTab:
+		return []string{"←", "→", "Home", "End", "Del", "↑", "↓", "PgUp", "PgDn", "Ins", "Shift-Tab"}[key-keyLeft]
+	case key >= keyF1 && key <= keyF12:
+		return fmt.Sprintf("F%d", key-keyF1+1)
+	case key == keyPasteStart, key == keyPasteEnd:
+		return "Paste"
+	case key == keyResize:
+		return "Resize"
 	}
-
-	beep()
-	return 0
+	return fmt.Sprintf("key %d", key)
 }
 
 // ─────────────────────────────────────────────────────────────
@@ -116,19 +129,93 @@ func clen(b []byte) int {
 	return len(b)
 }
 
-func insertChar(buffer []byte, pos int, ch byte) {
+// insertString inserts s at byte offset pos, reporting false if it does
+// not fit.
+func insertString(buffer []byte, pos int, s string) bool {
+	return replaceRange(buffer, pos, pos, s)
+}
+
+// insertRune inserts r at byte offset pos, shifting the rest of the
+// NUL-terminated contents right.  It returns the number of bytes
+// inserted, or 0 if r does not fit (one byte is kept for the NUL).
+func insertRune(buffer []byte, pos int, r rune) int {
+	n := utf8.RuneLen(r)
 	length := clen(buffer)
-	for i := length; i >= pos; i-- {
-		buffer[i+1] = buffer[i]
+	if n < 0 || length+n >= len(buffer) {
+		return 0
 	}
-	buffer[pos] = ch
+	copy(buffer[pos+n:], buffer[pos:length+1])
+	utf8.EncodeRune(buffer[pos:], r)
+	return n
 }
 
-func deleteChar(buffer []byte, pos int) {
+// replaceRune overwrites the character at pos with r (appending if pos
+// is at the end).  It returns the number of bytes r occupies, or 0 if
+// the result would not fit.
+func replaceRune(buffer []byte, pos int, r rune) int {
 	length := clen(buffer)
-	for i := pos; i < length; i++ {
-		buffer[i] = buffer[i+1]
+	old := 0
+	if pos < length {
+		_, old = utf8.DecodeRune(buffer[pos:length])
 	}
+	n := utf8.RuneLen(r)
+	if n < 0 || length-old+n >= len(buffer) {
+		return 0
+	}
+	deleteRune(buffer, pos)
+	return insertRune(buffer, pos, r)
+}
+
+// deleteRune removes the character starting at byte offset pos and
+// returns its length in bytes (0 if pos is at the end).
+func deleteRune(buffer []byte, pos int) int {
+	length := clen(buffer)
+	if pos >= length {
+		return 0
+	}
+	_, n := utf8.DecodeRune(buffer[pos:length])
+	copy(buffer[pos:], buffer[pos+n:length])
+	buffer[length-n] = 0
+	return n
+}
+
+// setBuffer replaces the NUL-terminated contents with s, cut short at a
+// character boundary if s does not fit.  It returns the new length.
+func setBuffer(buffer []byte, s string) int {
+	n := len(s)
+	for n >= len(buffer) || (n < len(s) && !utf8.RuneStart(s[n])) {
+		n--
+	}
+	copy(buffer, s[:n])
+	buffer[n] = 0
+	return n
+}
+
+// replaceRange replaces buffer[start:end] with s.  It reports false,
+// leaving the buffer unchanged, if the result would not fit.
+func replaceRange(buffer []byte, start, end int, s string) bool {
+	length := clen(buffer)
+	newLen := length - (end - start) + len(s)
+	if newLen >= len(buffer) {
+		return false
+	}
+	copy(buffer[start+len(s):], buffer[end:length])
+	copy(buffer[start:], s)
+	buffer[newLen] = 0
+	return true
+}
+
+// deleteRange removes buffer[start:end] from the NUL-terminated contents.
+func deleteRange(buffer []byte, start, end int) {
+	length := clen(buffer)
+	copy(buffer[start:], buffer[end:length])
+	buffer[length-(end-start)] = 0
+}
+
+// prevRune returns the byte offset of the character before pos.
+func prevRune(buffer []byte, pos int) int {
+	_, n := utf8.DecodeLastRune(buffer[:pos])
+	return pos - n
 }
 
 // ─────────────────────────────────────────────────────────────
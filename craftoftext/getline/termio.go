@@ -0,0 +1,314 @@
+// termio.go
+//
+// The Terminal every GetLine version reads keys from and draws on.
+//
+// GetLine never touches stdin or stdout itself: it goes through a
+// Terminal, so the same editor can run on the process's own terminal
+// (stdTerminal, the default), over any byte stream such as an SSH
+// channel or a websocket (streamTerminal), or against a scripted fake in
+// tests.
+
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// Terminal is what GetLine needs from a terminal.
+type Terminal interface {
+	// ReadKey returns the next key: a character, or one of the named
+	// keys of terminal.go with any modifier bits.  An escape sequence
+	// it does not know comes back as 0, and keyResize says the
+	// terminal has changed size since the last key.
+	ReadKey() (int, error)
+
+	// Write draws on the terminal, which is expected to understand
+	// the usual ANSI (VT100) escape sequences.  Lines end in "\r\n".
+	Write(p []byte) (int, error)
+
+	// Size returns the terminal's width and height in cells.
+	Size() (width, height int, err error)
+
+	// MakeRaw turns off the terminal's own line editing and echo for
+	// the length of a GetLine call; Restore turns them back on.  Calls
+	// nest.
+	MakeRaw() error
+	Restore() error
+
+	// Beep alerts the user to a key that could not be used.
+	Beep()
+}
+
+// orStdTerminal returns t, or the process's terminal if t is nil.
+func orStdTerminal(t Terminal) Terminal {
+	if t != nil {
+		return t
+	}
+	return stdTerminal{}
+}
+
+// ─────────────────────────────────────────────────────────────
+// Reading keys
+// ─────────────────────────────────────────────────────────────
+
+// escTimeout is how long to wait after Esc for the rest of an escape
+// sequence.  A terminal sends a whole sequence at once, so if nothing
+// follows within this time the user pressed Esc on its own.
+const escTimeout = 50 * time.Millisecond
+
+// errResized is returned by a byteSource's readByte when the terminal
+// changes size while it waits, and becomes keyResize.
+var errResized = errors.New("terminal resized")
+
+// errReadCancelled is returned by a byteSource's readByte, and so by
+// ReadKey, when cancelRead wakes it.
+var errReadCancelled = errors.New("read cancelled")
+
+// readCanceller is a Terminal whose ReadKey can be woken from another
+// goroutine: after cancelRead, a ReadKey that is waiting, or else the
+// next one to wait, returns errReadCancelled.  GetLineContext uses it to
+// give up on a line without leaving a read behind.
+type readCanceller interface {
+	cancelRead()
+}
+
+// byteSource is the input side of a terminal.
+type byteSource interface {
+	readByte() (byte, error)
+
+	// readByteTimeout reads a byte if one arrives within d.
+	readByteTimeout(d time.Duration) (byte, bool)
+}
+
+// readKey reads a key from src, decoding UTF-8 and escape sequences.
+func readKey(src byteSource) (int, error) {
+	lead, err := src.readByte()
+	if err == errResized {
+		return keyResize, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return decodeKey(readRune(src, lead), func() int {
+		b, ok := src.readByteTimeout(escTimeout)
+		if !ok {
+			return -1
+		}
+		return readRune(src, b)
+	}), nil
+}
+
+// readRune reads the rest of the UTF-8 sequence starting with lead, so
+// "é" or "日" arrive as a single key.  A malformed sequence is reported
+// as utf8.RuneError.
+func readRune(src byteSource, lead byte) int {
+	if lead < utf8.RuneSelf {
+		return int(lead)
+	}
+
+	var n int
+	switch {
+	case lead&0xE0 == 0xC0:
+		n = 2
+	case lead&0xF0 == 0xE0:
+		n = 3
+	case lead&0xF8 == 0xF0:
+		n = 4
+	default:
+		return utf8.RuneError // stray continuation or invalid lead byte
+	}
+
+	seq := []byte{lead}
+	for len(seq) < n {
+		b, err := src.readByte()
+		if err != nil {
+			return utf8.RuneError
+		}
+		seq = append(seq, b)
+	}
+	r, _ := utf8.DecodeRune(seq)
+	return int(r)
+}
+
+// asyncReader reads an io.Reader in blocks from a goroutine, so a read
+// can give up after a timeout without losing the input that turns up
+// later.
+type asyncReader struct {
+	blocks    chan []byte
+	resized   chan struct{} // see resize
+	cancelled chan struct{} // see cancel
+	buf       []byte        // unread part of the last block
+	err       error         // the error that ended the input
+}
+
+func newAsyncReader(r io.Reader) *asyncReader {
+	a := &asyncReader{
+		blocks:    make(chan []byte),
+		resized:   make(chan struct{}, 1),
+		cancelled: make(chan struct{}, 1),
+	}
+	go func() {
+		for {
+			b := make([]byte, 256)
+			n, err := r.Read(b)
+			if n > 0 {
+				a.blocks <- b[:n]
+			}
+			if err != nil {
+				a.err = err
+				close(a.blocks)
+				return
+			}
+		}
+	}()
+	return a
+}
+
+// resize makes a readByte waiting for input, or the next one to wait,
+// return errResized.
+func (a *asyncReader) resize() {
+	select {
+	case a.resized <- struct{}{}:
+	default: // one is already pending
+	}
+}
+
+// cancel is resize for errReadCancelled.
+func (a *asyncReader) cancel() {
+	select {
+	case a.cancelled <- struct{}{}:
+	default:
+	}
+}
+
+func (a *asyncReader) readByte() (byte, error) {
+	for len(a.buf) == 0 {
+		select {
+		case b, ok := <-a.blocks:
+			if !ok {
+				return 0, a.err
+			}
+			a.buf = b
+		case <-a.resized:
+			return 0, errResized
+		case <-a.cancelled:
+			return 0, errReadCancelled
+		}
+	}
+	b := a.buf[0]
+	a.buf = a.buf[1:]
+	return b, nil
+}
+
+func (a *asyncReader) readByteTimeout(d time.Duration) (byte, bool) {
+	if len(a.buf) == 0 {
+		select {
+		case b, ok := <-a.blocks:
+			if !ok {
+				return 0, false
+			}
+			a.buf = b
+		case <-time.After(d):
+			return 0, false
+		}
+	}
+	b, err := a.readByte()
+	return b, err == nil
+}
+
+// ─────────────────────────────────────────────────────────────
+// The process's own terminal
+// ─────────────────────────────────────────────────────────────
+
+// stdTerminal is the terminal on stdin and stdout.
+type stdTerminal struct{}
+
+func (stdTerminal) ReadKey() (int, error) {
+	return readKey(stdin())
+}
+
+func (stdTerminal) Write(p []byte) (int, error) {
+	return os.Stdout.Write(p)
+}
+
+func (stdTerminal) Size() (int, int, error) {
+	return term.GetSize(int(os.Stdout.Fd()))
+}
+
+func (stdTerminal) MakeRaw() error {
+	return enterRaw()
+}
+
+func (stdTerminal) Restore() error {
+	leaveRaw()
+	return nil
+}
+
+func (stdTerminal) Beep() {
+	beep()
+}
+
+func (stdTerminal) cancelRead() {
+	cancelStdin()
+}
+
+// ─────────────────────────────────────────────────────────────
+// A terminal at the other end of a stream
+// ─────────────────────────────────────────────────────────────
+
+// streamTerminal is a terminal reached through a pair of streams, such
+// as an SSH session channel or a websocket.  Raw mode is the remote
+// end's business, so MakeRaw and Restore do nothing; the size is
+// whatever the owner last set, e.g. from an SSH window-change request.
+type streamTerminal struct {
+	in  *asyncReader
+	out io.Writer
+
+	mu            sync.Mutex
+	width, height int
+}
+
+func newStreamTerminal(r io.Reader, w io.Writer) *streamTerminal {
+	return &streamTerminal{in: newAsyncReader(r), out: w, width: 80, height: 24}
+}
+
+// setSize records a new size for the remote terminal, which ReadKey
+// reports as keyResize.
+func (t *streamTerminal) setSize(width, height int) {
+	t.mu.Lock()
+	t.width, t.height = width, height
+	t.mu.Unlock()
+	t.in.resize()
+}
+
+func (t *streamTerminal) ReadKey() (int, error) {
+	return readKey(t.in)
+}
+
+func (t *streamTerminal) Write(p []byte) (int, error) {
+	return t.out.Write(p)
+}
+
+func (t *streamTerminal) Size() (int, int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.width, t.height, nil
+}
+
+func (t *streamTerminal) MakeRaw() error { return nil }
+func (t *streamTerminal) Restore() error { return nil }
+
+func (t *streamTerminal) Beep() {
+	t.out.Write([]byte("\a"))
+}
+
+func (t *streamTerminal) cancelRead() {
+	t.in.cancel()
+}
@@ -0,0 +1,78 @@
+// undo.go
+//
+// Multi-level undo and redo for the editing versions (V4 onward).
+//
+// Rather than teaching every command how to reverse itself, the editor
+// compares the line with what it was before each key, once the key has
+// been handled, and keeps the earlier state whenever something
+// changed.  So inserts, deletes, replace-mode overwrites, kills, yanks
+// and Ctrl-R restores can all be undone the same way.  A run of typed
+// characters is one undo step.
+
+package main
+
+// lineState is a snapshot of the line and cursor.
+type lineState struct {
+	text   string
+	cursor int
+}
+
+type undoLog struct {
+	done   []lineState // states Ctrl-_ can go back to, newest last
+	undone []lineState // states undone, for redo
+	cur    lineState   // the line as of the last sync
+
+	// grouping is set while a run of typed characters is in progress;
+	// further typed characters join the same undo step.
+	grouping bool
+}
+
+func newUndoLog(buffer []byte, cursor int) *undoLog {
+	return &undoLog{cur: lineState{cstring(buffer), cursor}}
+}
+
+// sync is called by Feed after each key is handled.  If the key changed
+// the line, the state before it becomes an undo step (unless it was a
+// typed character continuing a run) and the redo list is dropped.
+func (u *undoLog) sync(buffer []byte, cursor int, selfInsert bool) {
+	st := lineState{cstring(buffer), cursor}
+	if st.text == u.cur.text {
+		if st.cursor != u.cur.cursor {
+			u.grouping = false // moving the cursor ends a run of typing
+		}
+		u.cur = st
+		return
+	}
+	if !selfInsert || !u.grouping {
+		u.done = append(u.done, u.cur)
+	}
+	u.grouping = selfInsert
+	u.undone = nil
+	u.cur = st
+}
+
+// undo puts back the line before the last edit and returns the cursor
+// that went with it.
+func (u *undoLog) undo(buffer []byte) (int, bool) {
+	return u.step(buffer, &u.done, &u.undone)
+}
+
+// redo reapplies the edit most recently undone.
+func (u *undoLog) redo(buffer []byte) (int, bool) {
+	return u.step(buffer, &u.undone, &u.done)
+}
+
+// step moves one state from the top of from to the line, saving the
+// current line on to.
+func (u *undoLog) step(buffer []byte, from, to *[]lineState) (int, bool) {
+	if len(*from) == 0 {
+		return u.cur.cursor, false
+	}
+	st := (*from)[len(*from)-1]
+	*from = (*from)[:len(*from)-1]
+	*to = append(*to, u.cur)
+	u.cur = st
+	u.grouping = false
+	setBuffer(buffer, st.text)
+	return st.cursor, true
+}
@@ -0,0 +1,525 @@
+// vi.go
+//
+// Vi editing mode for the editing versions (V4 onward).
+//
+// With lineOptions.ViMode set the line starts in insert mode, where keys
+// behave as usual; Esc switches to normal mode, where letters are
+// commands:
+//
+//	h l w b e 0 ^ $   motions, with an optional count (3w)
+//	x X s D C         delete / change characters or to end of line
+//	d{motion} dd      delete, c{motion} cc change (2dw, d3e, c$ ...)
+//	r R               replace one character / replace mode
+//	i a I A           back to insert mode
+//	p P               put the last deletion after / before the cursor
+//	u Ctrl-R          undo, redo
+//	.                 repeat the last change
+//	j k               history
+//
+// Normal mode is a small state machine of its own rather than a keymap:
+// counts and operators waiting for their motion don't fit key → command.
+// The last change is kept as the keys that made it, including any text
+// typed in insert mode afterwards, and "." simply feeds them in again.
+
+package main
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+type viState struct {
+	normal bool // in normal mode (false = insert mode)
+
+	count   int // count typed so far, 0 = none
+	opCount int // count typed before the operator, as in 2dw
+	pending int // 'd' or 'c' waiting for a motion, 'r' for its character
+
+	// cmd collects the keys of the command in progress, and of the
+	// insert-mode typing it leads to, for ".".
+	cmd       []int
+	cmdCount  int  // count the command was given, 0 = none
+	inserting bool // cmd continues until Esc
+	last      viChange
+}
+
+// viChange is a change "." can repeat.
+type viChange struct {
+	count int
+	keys  []int
+}
+
+// viInsertKeymap returns the keymap for vi insert mode: m with Esc
+// switching to normal mode.
+func viInsertKeymap(m *keymap) *keymap {
+	m = m.clone()
+	m.bind([]int{27}, "vi-movement-mode")
+	return m
+}
+
+// viDispatch sees every key first in vi mode.  It handles normal mode
+// itself and returns true; in insert mode it records the key for "."
+// and leaves it to the keymap.
+func (ed *lineEditor) viDispatch(key int) bool {
+	v := ed.vi
+	if v.normal {
+		ed.viNormal(key)
+		return true
+	}
+	if ed.pending == nil && key&keyMeta != 0 {
+		// Esc typed quickly before another key arrives as Alt-key.
+		ed.dispatch(27)
+		ed.dispatch(key &^ keyMeta)
+		return true
+	}
+	if v.inserting {
+		v.cmd = append(v.cmd, key)
+	}
+	return false
+}
+
+// viMovementMode leaves insert mode, as Esc does.
+func (ed *lineEditor) viMovementMode() {
+	v := ed.vi
+	if v == nil {
+		ed.out.Beep() // not in vi mode
+		return
+	}
+	ed.wasKey = true
+	ed.insert = true
+	v.normal = true
+	if v.inserting {
+		v.last = viChange{v.cmdCount, v.cmd}
+		v.inserting = false
+	}
+	v.cmd = nil
+	if ed.cursor > 0 {
+		ed.cursor = prevCluster(ed.buffer, ed.cursor) // vi steps back onto the last character typed
+	}
+	ed.refreshHelper()
+}
+
+// viNormal handles one key in normal mode.
+func (ed *lineEditor) viNormal(key int) {
+	v := ed.vi
+	ed.wasKey = true
+	ed.lastCmd = "" // vi deletions do not join up on the kill ring
+
+	if key&keyMeta != 0 {
+		ed.viNormal(27)
+		key &^= keyMeta
+	}
+
+	// Count digits; 0 is a motion unless it continues a count.
+	if v.pending != 'r' && (key >= '1' && key <= '9' || key == '0' && v.count > 0) {
+		v.count = v.count*10 + key - '0'
+		return
+	}
+
+	v.cmd = append(v.cmd, key)
+	n := max(v.opCount, 1) * max(v.count, 1)
+	v.cmdCount = 0
+	if v.opCount > 0 || v.count > 0 {
+		v.cmdCount = n
+	}
+
+	switch v.pending {
+	case 'r':
+		ed.viReplace(key, n)
+	case 'd', 'c':
+		ed.viOperator(v.pending, key, n)
+	default:
+		ed.viCommand(key, n)
+	}
+
+	if v.normal {
+		if length := clen(ed.buffer); ed.cursor >= length && length > 0 {
+			ed.cursor = prevCluster(ed.buffer, length) // normal mode sits on a character
+		}
+	}
+}
+
+// viCommand runs a normal-mode command that is not waiting for
+// anything.
+func (ed *lineEditor) viCommand(key, n int) {
+	v := ed.vi
+	length := clen(ed.buffer)
+
+	switch key {
+	case 'd', 'c':
+		v.pending, v.opCount, v.count = key, v.count, 0
+		return
+	case 'r':
+		v.pending = 'r'
+		return
+
+	case 'i':
+		ed.viInsert()
+	case 'a':
+		if ed.cursor < length {
+			ed.cursor = nextCluster(ed.buffer, ed.cursor)
+		}
+		ed.viInsert()
+	case 'I':
+		ed.cursor = 0
+		ed.viInsert()
+	case 'A':
+		ed.cursor = length
+		ed.viInsert()
+	case 'R':
+		ed.insert = false
+		ed.viInsert()
+
+	case 'x':
+		ed.viOperate('d', ed.cursor, ed.viRight(n))
+	case 'X':
+		ed.viOperate('d', ed.viLeft(n), ed.cursor)
+	case 's':
+		ed.viOperate('c', ed.cursor, ed.viRight(n))
+	case 'D':
+		ed.viOperate('d', ed.cursor, length)
+	case 'C':
+		ed.viOperate('c', ed.cursor, length)
+	case 'p', 'P':
+		ed.viPut(key == 'p')
+
+	case '.':
+		ed.viRepeat()
+	case 'u':
+		ed.viEnd(false)
+		ed.undoEdit()
+	case keyCtrlR:
+		ed.viEnd(false)
+		ed.redoEdit()
+	case 'k', keyUp:
+		ed.viEnd(false)
+		ed.previousHistory()
+	case 'j', keyDown:
+		ed.viEnd(false)
+		ed.nextHistory()
+	case 27:
+		ed.viEnd(false)
+		ed.out.Beep() // nothing to cancel
+
+	default:
+		ed.viEnd(false)
+		if pos, _, ok := ed.viMotion(key, n); ok {
+			ed.cursor = pos
+			return
+		}
+		// Enter, Ctrl-L, Ctrl-G and the like work as in insert mode.
+		if b, ok := ed.keys.lookup(key); ok && b.prefix == nil && !isSelfInsert(key) {
+			ed.key = key
+			commands[b.command](ed)
+			return
+		}
+		ed.out.Beep()
+	}
+}
+
+// viOperator applies a pending d or c to the text covered by the motion
+// key.  The operator doubled (dd, cc) covers the whole line.
+func (ed *lineEditor) viOperator(op, key, n int) {
+	if key == op {
+		ed.viOperate(op, 0, clen(ed.buffer))
+		return
+	}
+	var pos int
+	var inclusive, ok bool
+	if r, _ := utf8.DecodeRune(ed.buffer[ed.cursor:]); key == 'w' && op == 'c' && ed.cursor < clen(ed.buffer) && !isBlank(r) {
+		// cw changes to the end of the word, not the blanks after it.
+		pos, inclusive, ok = viRunEnd(ed.buffer, ed.cursor, ed.wordChars()), true, true
+		for range n - 1 {
+			pos = viWordEnd(ed.buffer, pos, ed.wordChars())
+		}
+	} else {
+		pos, inclusive, ok = ed.viMotion(key, n)
+	}
+	if !ok {
+		ed.viEnd(false)
+		ed.out.Beep()
+		return
+	}
+	start, end := min(ed.cursor, pos), max(ed.cursor, pos)
+	if inclusive && end < clen(ed.buffer) {
+		end = nextCluster(ed.buffer, end)
+	}
+	ed.viOperate(op, start, end)
+}
+
+// viOperate deletes buffer[start:end] onto the kill ring and, for c,
+// goes into insert mode in its place.
+func (ed *lineEditor) viOperate(op, start, end int) {
+	if start == end && op == 'd' {
+		ed.viEnd(false)
+		ed.out.Beep()
+		return
+	}
+	ed.kill(start, end)
+	if op == 'c' {
+		ed.viInsert()
+	} else {
+		ed.viEnd(true)
+	}
+}
+
+// viReplace overwrites n characters with the one typed after r.
+func (ed *lineEditor) viReplace(key, n int) {
+	r := rune(key)
+	ok := isSelfInsert(key) && (ed.filter == nil || ed.filter(r))
+	pos := ed.cursor
+	for i := 0; ok && i < n; i++ {
+		if pos >= clen(ed.buffer) {
+			ok = false
+			break
+		}
+		pos = nextCluster(ed.buffer, pos)
+	}
+	if !ok {
+		ed.viEnd(false)
+		ed.out.Beep()
+		return
+	}
+	for i := 0; i < n; i++ {
+		ed.reserve(utf8.RuneLen(r))
+		if !replaceRange(ed.buffer, ed.cursor, nextCluster(ed.buffer, ed.cursor), string(r)) {
+			ed.out.Beep() // does not fit
+			break
+		}
+		ed.cursor += utf8.RuneLen(r)
+	}
+	ed.cursor = prevCluster(ed.buffer, ed.cursor)
+	ed.viEnd(true)
+}
+
+// viPut inserts the newest kill after (p) or before (P) the cursor,
+// leaving the cursor on its last character.
+func (ed *lineEditor) viPut(after bool) {
+	pos := ed.cursor
+	if after && pos < clen(ed.buffer) {
+		pos = nextCluster(ed.buffer, pos)
+	}
+	ed.reserve(ed.kills.longest())
+	end, ok := ed.kills.yank(ed.buffer, pos)
+	if !ok {
+		ed.viEnd(false)
+		ed.out.Beep()
+		return
+	}
+	ed.cursor = prevCluster(ed.buffer, end)
+	ed.viEnd(true)
+}
+
+// viRepeat replays the last change, with the count typed before "." if
+// there is one.
+func (ed *lineEditor) viRepeat() {
+	v := ed.vi
+	last := v.last
+	count := v.cmdCount
+	ed.viEnd(false)
+	if last.keys == nil {
+		ed.out.Beep()
+		return
+	}
+	if count == 0 {
+		count = last.count
+	}
+	v.count = count
+	for _, key := range last.keys {
+		ed.handle(key)
+	}
+}
+
+// viInsert switches to insert mode.  The command that led here stays
+// recorded until Esc, so "." repeats the text typed as well.
+func (ed *lineEditor) viInsert() {
+	v := ed.vi
+	v.normal = false
+	v.inserting = true
+	v.count, v.opCount, v.pending = 0, 0, 0
+	ed.refreshHelper()
+}
+
+// viEnd finishes a normal-mode command, keeping it for "." if it
+// changed the line.
+func (ed *lineEditor) viEnd(change bool) {
+	v := ed.vi
+	if change {
+		v.last = viChange{v.cmdCount, v.cmd}
+	}
+	v.cmd = nil
+	v.count, v.opCount, v.pending = 0, 0, 0
+}
+
+// ─────────────────────────────────────────────────────────────
+// Motions
+// ─────────────────────────────────────────────────────────────
+
+// viMotion returns where motion key, repeated n times, moves the cursor
+// to.  inclusive is set for motions whose target character is part of
+// the text an operator covers (e).
+func (ed *lineEditor) viMotion(key, n int) (pos int, inclusive, ok bool) {
+	isWord := ed.wordChars()
+	pos = ed.cursor
+	switch key {
+	case 'h', keyLeft, keyBack:
+		return ed.viLeft(n), false, true
+	case 'l', keyRight, ' ':
+		return ed.viRight(n), false, true
+	case 'w':
+		for range n {
+			pos = viWordForward(ed.buffer, pos, isWord)
+		}
+	case 'b':
+		for range n {
+			pos = viWordBackward(ed.buffer, pos, isWord)
+		}
+	case 'e':
+		for range n {
+			pos = viWordEnd(ed.buffer, pos, isWord)
+		}
+		return pos, true, true
+	case '0', keyHome:
+		pos = 0
+	case '^':
+		pos = 0
+		for length := clen(ed.buffer); pos < length; {
+			r, n := utf8.DecodeRune(ed.buffer[pos:length])
+			if !isBlank(r) {
+				break
+			}
+			pos += n
+		}
+	case '$', keyEnd:
+		pos = clen(ed.buffer)
+	default:
+		return ed.cursor, false, false
+	}
+	return pos, false, true
+}
+
+func (ed *lineEditor) viLeft(n int) int {
+	pos := ed.cursor
+	for i := 0; i < n && pos > 0; i++ {
+		pos = prevCluster(ed.buffer, pos)
+	}
+	return pos
+}
+
+func (ed *lineEditor) viRight(n int) int {
+	pos, length := ed.cursor, clen(ed.buffer)
+	for i := 0; i < n && pos < length; i++ {
+		pos = nextCluster(ed.buffer, pos)
+	}
+	return pos
+}
+
+// viClass sorts characters for the vi word motions, where a run of word
+// characters and a run of other non-blank characters are both words.
+func viClass(r rune, isWord func(rune) bool) int {
+	switch {
+	case unicode.IsSpace(r):
+		return 0
+	case isWord(r):
+		return 1
+	}
+	return 2
+}
+
+// viWordForward returns the start of the next word after pos (w).
+func viWordForward(buffer []byte, pos int, isWord func(rune) bool) int {
+	length := clen(buffer)
+	if pos < length {
+		r, _ := utf8.DecodeRune(buffer[pos:length])
+		if c := viClass(r, isWord); c != 0 {
+			for pos < length {
+				r, n := utf8.DecodeRune(buffer[pos:length])
+				if viClass(r, isWord) != c {
+					break
+				}
+				pos += n
+			}
+		}
+	}
+	for pos < length {
+		r, n := utf8.DecodeRune(buffer[pos:length])
+		if !isBlank(r) {
+			break
+		}
+		pos += n
+	}
+	return pos
+}
+
+// viWordBackward returns the start of the word before pos (b).
+func viWordBackward(buffer []byte, pos int, isWord func(rune) bool) int {
+	for pos > 0 {
+		r, n := utf8.DecodeLastRune(buffer[:pos])
+		if !isBlank(r) {
+			break
+		}
+		pos -= n
+	}
+	if pos == 0 {
+		return 0
+	}
+	r, _ := utf8.DecodeLastRune(buffer[:pos])
+	c := viClass(r, isWord)
+	for pos > 0 {
+		r, n := utf8.DecodeLastRune(buffer[:pos])
+		if viClass(r, isWord) != c {
+			break
+		}
+		pos -= n
+	}
+	return pos
+}
+
+// viRunEnd returns the last character of the word pos is in.
+func viRunEnd(buffer []byte, pos int, isWord func(rune) bool) int {
+	length := clen(buffer)
+	r, n := utf8.DecodeRune(buffer[pos:length])
+	c := viClass(r, isWord)
+	for next := pos + n; next < length; next += n {
+		r, n = utf8.DecodeRune(buffer[next:length])
+		if viClass(r, isWord) != c {
+			break
+		}
+		pos = next
+	}
+	return pos
+}
+
+// viWordEnd returns the last character of the word ending after pos (e).
+func viWordEnd(buffer []byte, pos int, isWord func(rune) bool) int {
+	length := clen(buffer)
+	if pos >= length {
+		return pos
+	}
+	_, n := utf8.DecodeRune(buffer[pos:length])
+	next := pos + n
+	for next < length {
+		r, n := utf8.DecodeRune(buffer[next:length])
+		if !isBlank(r) {
+			break
+		}
+		next += n
+	}
+	if next >= length {
+		return pos // no further word
+	}
+	r, _ := utf8.DecodeRune(buffer[next:length])
+	c := viClass(r, isWord)
+	for {
+		pos = next
+		r, n := utf8.DecodeRune(buffer[pos:length])
+		next = pos + n
+		if next >= length {
+			break
+		}
+		if r, _ = utf8.DecodeRune(buffer[next:length]); viClass(r, isWord) != c {
+			break
+		}
+	}
+	return pos
+}
@@ -0,0 +1,237 @@
+// vt100_test.go
+//
+// A small VT100 emulator for the tests: enough of one to show what
+// GetLine's output leaves on the screen.  It understands printable text
+// (with wide and combining characters), CR, LF, BS, Tab, and the CSI
+// sequences for moving the cursor and erasing; other sequences are
+// consumed and ignored.
+
+package main
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+type vt100 struct {
+	width, height int
+	cells         [][]string // "" = blank; a wide character's second cell is wideTail
+	row, col      int
+	wrapNext      bool // the last column was just written; the next character wraps
+
+	state   int    // vtGround, vtEscape or vtCSI
+	params  []byte // of the CSI sequence being read
+	partial []byte // incomplete UTF-8 sequence
+}
+
+const (
+	vtGround = iota
+	vtEscape
+	vtCSI
+)
+
+const wideTail = "\x00"
+
+func newVT100(width, height int) *vt100 {
+	v := &vt100{width: width, height: height}
+	v.cells = make([][]string, height)
+	for i := range v.cells {
+		v.cells[i] = make([]string, width)
+	}
+	return v
+}
+
+// resize changes the screen's size the way xterm does: rows and columns
+// are cut off or added at the bottom and right, without reflowing.
+func (v *vt100) resize(width, height int) {
+	cells := make([][]string, height)
+	for r := range cells {
+		cells[r] = make([]string, width)
+		if r < v.height {
+			copy(cells[r], v.cells[r])
+		}
+	}
+	v.width, v.height, v.cells = width, height, cells
+	v.row, v.col = min(v.row, height-1), min(v.col, width-1)
+	v.wrapNext = false
+}
+
+func (v *vt100) Write(p []byte) (int, error) {
+	data := append(v.partial, p...)
+	v.partial = nil
+	for len(data) > 0 {
+		if !utf8.FullRune(data) {
+			v.partial = append([]byte(nil), data...)
+			break
+		}
+		r, n := utf8.DecodeRune(data)
+		data = data[n:]
+		v.feed(r)
+	}
+	return len(p), nil
+}
+
+func (v *vt100) feed(r rune) {
+	switch v.state {
+	case vtEscape:
+		v.state = vtGround
+		if r == '[' {
+			v.state, v.params = vtCSI, v.params[:0]
+		}
+		return
+	case vtCSI:
+		if r >= 0x20 && r <= 0x3F {
+			v.params = append(v.params, byte(r))
+		} else {
+			v.state = vtGround
+			v.csi(r, string(v.params))
+		}
+		return
+	}
+
+	switch r {
+	case 27:
+		v.state = vtEscape
+	case '\r':
+		v.col, v.wrapNext = 0, false
+	case '\n':
+		v.lineFeed()
+	case '\b':
+		v.col, v.wrapNext = max(v.col-1, 0), false
+	case '\t':
+		v.col = min((v.col/8+1)*8, v.width-1)
+	case '\a':
+	default:
+		if r >= ' ' {
+			v.put(r)
+		}
+	}
+}
+
+// put writes a printable character at the cursor.
+func (v *vt100) put(r rune) {
+	w := runeWidth(r)
+	if w == 0 {
+		// Combining: joins the character before the cursor.
+		row, col := v.row, v.col-1
+		if v.wrapNext {
+			col = v.col
+		}
+		if col >= 0 {
+			if v.cells[row][col] == wideTail && col > 0 {
+				col--
+			}
+			v.cells[row][col] += string(r)
+		}
+		return
+	}
+	if v.wrapNext || v.col+w > v.width {
+		v.col, v.wrapNext = 0, false
+		v.lineFeed()
+	}
+	v.cells[v.row][v.col] = string(r)
+	if w == 2 {
+		v.cells[v.row][v.col+1] = wideTail
+	}
+	v.col += w
+	if v.col >= v.width {
+		v.col, v.wrapNext = v.width-1, true
+	}
+}
+
+func (v *vt100) lineFeed() {
+	v.wrapNext = false
+	if v.row < v.height-1 {
+		v.row++
+		return
+	}
+	copy(v.cells, v.cells[1:])
+	v.cells[v.height-1] = make([]string, v.width)
+}
+
+func (v *vt100) csi(final rune, params string) {
+	if strings.HasPrefix(params, "?") {
+		return // private modes
+	}
+	var nums []int
+	for _, p := range strings.Split(params, ";") {
+		n, _ := strconv.Atoi(p)
+		nums = append(nums, n)
+	}
+	arg := func(i, def int) int {
+		if i < len(nums) && nums[i] > 0 {
+			return nums[i]
+		}
+		return def
+	}
+
+	v.wrapNext = false
+	switch final {
+	case 'A':
+		v.row = max(v.row-arg(0, 1), 0)
+	case 'B':
+		v.row = min(v.row+arg(0, 1), v.height-1)
+	case 'C':
+		v.col = min(v.col+arg(0, 1), v.width-1)
+	case 'D':
+		v.col = max(v.col-arg(0, 1), 0)
+	case 'G':
+		v.col = min(arg(0, 1), v.width) - 1
+	case 'H', 'f':
+		v.row = min(arg(0, 1), v.height) - 1
+		v.col = min(arg(1, 1), v.width) - 1
+	case 'K':
+		switch arg(0, 0) {
+		case 0:
+			v.erase(v.row, v.col, v.width)
+		case 1:
+			v.erase(v.row, 0, v.col+1)
+		case 2:
+			v.erase(v.row, 0, v.width)
+		}
+	case 'J':
+		switch arg(0, 0) {
+		case 0:
+			v.erase(v.row, v.col, v.width)
+			for r := v.row + 1; r < v.height; r++ {
+				v.erase(r, 0, v.width)
+			}
+		case 2:
+			for r := range v.height {
+				v.erase(r, 0, v.width)
+			}
+		}
+	}
+}
+
+func (v *vt100) erase(row, from, to int) {
+	for c := from; c < to; c++ {
+		v.cells[row][c] = ""
+	}
+}
+
+// line returns the text of a screen row without trailing blanks.
+func (v *vt100) line(row int) string {
+	var sb strings.Builder
+	for _, c := range v.cells[row] {
+		switch c {
+		case "":
+			sb.WriteByte(' ')
+		case wideTail:
+		default:
+			sb.WriteString(c)
+		}
+	}
+	return strings.TrimRight(sb.String(), " ")
+}
+
+// text returns the whole screen, one line per row, without trailing
+// blank rows.
+func (v *vt100) text() string {
+	lines := make([]string, v.height)
+	for r := range lines {
+		lines[r] = v.line(r)
+	}
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n")
+}
@@ -0,0 +1,185 @@
+// width.go
+//
+// Display-width helpers shared by the redisplay code in V4 onward.
+//
+// A terminal does not give every character one column: East Asian wide
+// and fullwidth characters take two cells, combining marks take none and
+// draw on top of the preceding character.  The editor therefore works in
+// grapheme clusters — a base character plus everything that attaches to
+// it — and measures them in cells rather than bytes.
+
+package main
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// ─────────────────────────────────────────────────────────────
+// Character width
+// ─────────────────────────────────────────────────────────────
+
+// wideTable lists the East Asian Wide (W) and Fullwidth (F) ranges,
+// plus the emoji blocks that terminals render in two cells.
+var wideTable = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{0x1100, 0x115F, 1}, // Hangul Jamo initial consonants
+		{0x231A, 0x231B, 1},
+		{0x2329, 0x232A, 1},
+		{0x23E9, 0x23EC, 1},
+		{0x23F0, 0x23F3, 3},
+		{0x25FD, 0x25FE, 1},
+		{0x2614, 0x2615, 1},
+		{0x2648, 0x2653, 1},
+		{0x267F, 0x2693, 20},
+		{0x26A1, 0x26A1, 1},
+		{0x26AA, 0x26AB, 1},
+		{0x26BD, 0x26BE, 1},
+		{0x26C4, 0x26C5, 1},
+		{0x26CE, 0x26D4, 6},
+		{0x26EA, 0x26EA, 1},
+		{0x26F2, 0x26F3, 1},
+		{0x26F5, 0x26FA, 5},
+		{0x26FD, 0x26FD, 1},
+		{0x2705, 0x2705, 1},
+		{0x270A, 0x270B, 1},
+		{0x2728, 0x2728, 1},
+		{0x274C, 0x274E, 2},
+		{0x2753, 0x2755, 1},
+		{0x2757, 0x2757, 1},
+		{0x2795, 0x2797, 1},
+		{0x27B0, 0x27BF, 15},
+		{0x2B1B, 0x2B1C, 1},
+		{0x2B50, 0x2B55, 5},
+		{0x2E80, 0x303E, 1}, // CJK radicals, Kangxi, CJK symbols
+		{0x3041, 0x33FF, 1}, // Hiragana, Katakana, Bopomofo, CJK compat
+		{0x3400, 0x4DBF, 1}, // CJK Extension A
+		{0x4E00, 0x9FFF, 1}, // CJK Unified Ideographs
+		{0xA000, 0xA4CF, 1}, // Yi
+		{0xA960, 0xA97F, 1}, // Hangul Jamo Extended-A
+		{0xAC00, 0xD7A3, 1}, // Hangul syllables
+		{0xF900, 0xFAFF, 1}, // CJK compatibility ideographs
+		{0xFE10, 0xFE19, 1}, // vertical forms
+		{0xFE30, 0xFE6F, 1}, // CJK compatibility forms, small forms
+		{0xFF00, 0xFF60, 1}, // fullwidth forms
+		{0xFFE0, 0xFFE6, 1},
+	},
+	R32: []unicode.Range32{
+		{0x16FE0, 0x16FE4, 1},
+		{0x17000, 0x18AFF, 1}, // Tangut
+		{0x1B000, 0x1B2FF, 1}, // Kana supplement, Nushu
+		{0x1F004, 0x1F004, 1},
+		{0x1F0CF, 0x1F0CF, 1},
+		{0x1F18E, 0x1F18E, 1},
+		{0x1F191, 0x1F19A, 1},
+		{0x1F1E6, 0x1F1FF, 1}, // regional indicators (flags)
+		{0x1F200, 0x1F251, 1},
+		{0x1F300, 0x1F64F, 1}, // pictographs, emoticons
+		{0x1F680, 0x1F6FF, 1}, // transport and map symbols
+		{0x1F900, 0x1F9FF, 1}, // supplemental symbols and pictographs
+		{0x1FA70, 0x1FAFF, 1},
+		{0x20000, 0x2FFFD, 1}, // CJK Extensions B–F
+		{0x30000, 0x3FFFD, 1}, // CJK Extension G
+	},
+}
+
+// runeWidth returns the number of terminal cells r occupies: 0 for
+// combining marks, format and control characters, 2 for wide and
+// fullwidth characters, 1 otherwise.
+func runeWidth(r rune) int {
+	switch {
+	case r < 0x20 || r == 0x7F:
+		return 0
+	case r < 0x300:
+		return 1 // fast path for Latin-1 and friends
+	case isExtend(r) || unicode.Is(unicode.Cf, r):
+		return 0
+	case unicode.Is(wideTable, r):
+		return 2
+	}
+	return 1
+}
+
+// ─────────────────────────────────────────────────────────────
+// Grapheme clusters
+// ─────────────────────────────────────────────────────────────
+
+const zwj = 0x200D // zero width joiner
+
+// isExtend reports whether r attaches to the character before it
+// rather than starting a new cluster.
+func isExtend(r rune) bool {
+	return unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc) ||
+		r == zwj ||
+		(r >= 0x1160 && r <= 0x11FF) || // Hangul medial vowels and final consonants
+		(r >= 0xFE00 && r <= 0xFE0F) || // variation selectors
+		(r >= 0x1F3FB && r <= 0x1F3FF) || // emoji skin-tone modifiers
+		(r >= 0xE0020 && r <= 0xE007F) || // emoji tag sequences
+		(r >= 0xE0100 && r <= 0xE01EF) // variation selectors supplement
+}
+
+func isRegional(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
+
+// nextCluster returns the byte offset just past the grapheme cluster
+// that starts at pos in the NUL-terminated buffer.
+func nextCluster(buffer []byte, pos int) int {
+	return clusterEnd(buffer[:clen(buffer)], pos)
+}
+
+// prevCluster returns the byte offset of the start of the grapheme
+// cluster that ends at pos.
+func prevCluster(buffer []byte, pos int) int {
+	b := buffer[:clen(buffer)]
+	start := 0
+	for end := 0; end < pos; end = clusterEnd(b, end) {
+		start = end
+	}
+	return start
+}
+
+// clusterEnd scans one cluster of b starting at pos: a base character
+// followed by any extending characters, with a ZWJ gluing on the next
+// character and regional indicators pairing up into a flag.
+func clusterEnd(b []byte, pos int) int {
+	if pos >= len(b) {
+		return pos
+	}
+	base, n := utf8.DecodeRune(b[pos:])
+	pos += n
+	if base == '\r' && pos < len(b) && b[pos] == '\n' {
+		return pos + 1
+	}
+	if isRegional(base) {
+		if r, n := utf8.DecodeRune(b[pos:]); isRegional(r) {
+			pos += n
+		}
+	}
+	for pos < len(b) {
+		r, n := utf8.DecodeRune(b[pos:])
+		if !isExtend(r) {
+			break
+		}
+		pos += n
+		if r == zwj && pos < len(b) {
+			_, n = utf8.DecodeRune(b[pos:])
+			pos += n
+		}
+	}
+	return pos
+}
+
+// displayWidth returns the number of cells needed to show b.  Each
+// cluster is as wide as its base character, so a ZWJ emoji sequence or
+// a letter with several accents still counts once.
+func displayWidth(b []byte) int {
+	w := 0
+	for pos := 0; pos < len(b); {
+		end := clusterEnd(b, pos)
+		base, _ := utf8.DecodeRune(b[pos:end])
+		w += runeWidth(base)
+		pos = end
+	}
+	return w
+}
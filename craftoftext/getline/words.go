@@ -0,0 +1,131 @@
+// words.go
+//
+// Word motion and editing commands for the editing versions (V4 onward).
+//
+// What counts as a word is decided by a character class — a func(rune)
+// bool — rather than by ASCII ranges, so words in any script work, and
+// lineOptions.WordChars can change the class (to treat '_' or '-' as part
+// of a word, say).
+
+package main
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// isWordRune is the default word character class: letters, digits and
+// the marks that combine with them, in any script.
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r)
+}
+
+// backwardWord returns the start of the word before pos: it skips any
+// non-word characters and then the word characters before them.
+func backwardWord(buffer []byte, pos int, isWord func(rune) bool) int {
+	for pos > 0 {
+		r, n := utf8.DecodeLastRune(buffer[:pos])
+		if isWord(r) {
+			break
+		}
+		pos -= n
+	}
+	for pos > 0 {
+		r, n := utf8.DecodeLastRune(buffer[:pos])
+		if !isWord(r) {
+			break
+		}
+		pos -= n
+	}
+	return pos
+}
+
+// isBlank separates the whitespace-delimited "words" that Ctrl-W
+// removes, as in the Unix terminal driver.
+func isBlank(r rune) bool {
+	return unicode.IsSpace(r)
+}
+
+func isNotBlank(r rune) bool {
+	return !isBlank(r)
+}
+
+// forwardWord returns the end of the word at or after pos: it skips any
+// non-word characters and then the word characters after them.
+func forwardWord(buffer []byte, pos int, isWord func(rune) bool) int {
+	length := clen(buffer)
+	for pos < length {
+		r, n := utf8.DecodeRune(buffer[pos:length])
+		if isWord(r) {
+			break
+		}
+		pos += n
+	}
+	for pos < length {
+		r, n := utf8.DecodeRune(buffer[pos:length])
+		if !isWord(r) {
+			break
+		}
+		pos += n
+	}
+	return pos
+}
+
+// ─────────────────────────────────────────────────────────────
+// Word and character commands
+// ─────────────────────────────────────────────────────────────
+
+// Case conversions for caseWord.
+var (
+	upcase   = func(first bool, r rune) rune { return unicode.ToUpper(r) }
+	downcase = func(first bool, r rune) rune { return unicode.ToLower(r) }
+)
+
+func capitalize(first bool, r rune) rune {
+	if first {
+		return unicode.ToTitle(r)
+	}
+	return unicode.ToLower(r)
+}
+
+// caseWord converts the characters from pos to the end of the next word
+// with conv, which is told whether it sees the first letter of the word.
+// It returns the cursor after the word, or false if the converted text
+// no longer fits.
+func caseWord(buffer []byte, pos int, isWord func(rune) bool, conv func(first bool, r rune) rune) (int, bool) {
+	end := forwardWord(buffer, pos, isWord)
+	out := make([]rune, 0, end-pos)
+	first := true
+	for _, r := range string(buffer[pos:end]) {
+		if isWord(r) {
+			out = append(out, conv(first, r))
+			first = false
+		} else {
+			out = append(out, r)
+		}
+	}
+	text := string(out)
+	if !replaceRange(buffer, pos, end, text) {
+		return pos, false
+	}
+	return pos + len(text), true
+}
+
+// transposeChars swaps the character before pos with the one at pos and
+// moves past both, as Ctrl-T does in Emacs; at the end of the line it
+// swaps the last two characters instead.  Characters here are grapheme
+// clusters, so an accented letter moves as a whole.
+func transposeChars(buffer []byte, pos int) (int, bool) {
+	length := clen(buffer)
+	if pos == length {
+		pos = prevCluster(buffer, pos)
+	}
+	if pos == 0 || pos == length {
+		return pos, false
+	}
+	start := prevCluster(buffer, pos)
+	end := nextCluster(buffer, pos)
+	swapped := string(buffer[pos:end]) + string(buffer[start:pos])
+	copy(buffer[start:end], swapped)
+	return end, true
+}